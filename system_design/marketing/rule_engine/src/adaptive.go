@@ -0,0 +1,210 @@
+package main
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// AdaptiveConfig 配置 AND/OR 子条件按运行期选择性自适应重排
+type AdaptiveConfig struct {
+	// SampleEvery 每隔多少次执行采样一次子条件的耗时与结果，<=0 时取 16
+	SampleEvery int
+	// ReorderEvery 每累计多少次采样重新计算一次执行顺序，<=0 时取 64
+	ReorderEvery int
+}
+
+func (c AdaptiveConfig) normalized() AdaptiveConfig {
+	if c.SampleEvery <= 0 {
+		c.SampleEvery = 16
+	}
+	if c.ReorderEvery <= 0 {
+		c.ReorderEvery = 64
+	}
+	return c
+}
+
+// adaptiveJunction 为可重排的 AND/OR 节点。执行顺序按采样得到的 耗时/短路概率 升序排列，
+// 便宜且容易短路的子条件先执行。语义保持：
+//   - 只移动不会出错的子条件：严格类型模式下右值为常量的 eq/ne/in 叶子；其余子条件固定在原位，
+//     作为屏障分段，重排只发生在两个屏障之间
+//   - 固定子条件之前的子条件无论顺序如何都已执行且未短路，它出错时与原顺序返回同一个错误
+//   - 可移动叶子的路径上有尚未加载的 loader 时，loader 可能出错，本次评估按原顺序执行
+//   - 编译期折叠出的短路常量固定在末尾
+type adaptiveJunction struct {
+	shortCircuit bool
+	children     []func(*Fact) (bool, error)
+	movable      []bool
+	// 可移动叶子读取的路径，评估前检查其上的 loader
+	paths    []factPath
+	stats    []childStats
+	order    atomic.Value // *junctionOrder
+	identity *junctionOrder
+	calls    uint64
+	// 采样计数与重排互斥标记
+	samples      uint64
+	reordering   int32
+	sampleEvery  uint64
+	reorderEvery uint64
+}
+
+type junctionOrder struct {
+	index    []int
+	identity bool
+}
+
+// childStats 为子条件的采样统计，耗时包含其触发的 loader 调用
+type childStats struct {
+	calls  int64
+	shorts int64 // 结果等于短路值的次数
+	nanos  int64
+}
+
+// reorderable 判断子条件中是否有相邻的可移动子条件，没有时重排不会改变执行顺序
+func reorderable(nodes []compiledNode) bool {
+	for i := 1; i < len(nodes); i++ {
+		if nodes[i-1].movable && nodes[i].movable {
+			return true
+		}
+	}
+	return false
+}
+
+func newAdaptiveJunction(shortCircuit bool, nodes []compiledNode, config AdaptiveConfig) *adaptiveJunction {
+	j := &adaptiveJunction{
+		shortCircuit: shortCircuit,
+		children:     make([]func(*Fact) (bool, error), len(nodes)),
+		movable:      make([]bool, len(nodes)),
+		stats:        make([]childStats, len(nodes)),
+		sampleEvery:  uint64(config.SampleEvery),
+		reorderEvery: uint64(config.ReorderEvery),
+	}
+	index := make([]int, len(nodes))
+	for i, node := range nodes {
+		index[i] = i
+		j.children[i] = node.eval
+		j.movable[i] = node.movable
+		if node.movable {
+			j.paths = append(j.paths, node.path)
+		}
+	}
+	j.identity = &junctionOrder{index: index, identity: true}
+	j.order.Store(j.identity)
+	return j
+}
+
+func (j *adaptiveJunction) eval(fact *Fact) (bool, error) {
+	order := j.order.Load().(*junctionOrder)
+	if !order.identity && j.loading(fact) {
+		order = j.identity
+	}
+	sample := atomic.AddUint64(&j.calls, 1)%j.sampleEvery == 0
+	for _, i := range order.index {
+		var start time.Time
+		if sample {
+			start = time.Now()
+		}
+		ok, err := j.children[i](fact)
+		if err != nil {
+			return false, err
+		}
+		if sample {
+			j.stats[i].observe(ok == j.shortCircuit, time.Since(start))
+		}
+		if ok == j.shortCircuit {
+			if sample {
+				j.sampled()
+			}
+			return j.shortCircuit, nil
+		}
+	}
+	if sample {
+		j.sampled()
+	}
+	return !j.shortCircuit, nil
+}
+
+// loading 判断可移动叶子的路径上是否有尚未加载的 loader
+func (j *adaptiveJunction) loading(fact *Fact) bool {
+	st := fact.state
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	if len(st.loaders) == 0 {
+		return false
+	}
+	for _, path := range j.paths {
+		for k := range path.segments {
+			if _, ok := fact.pendingLoader(path.prefix(k)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *childStats) observe(short bool, elapsed time.Duration) {
+	atomic.AddInt64(&s.calls, 1)
+	atomic.AddInt64(&s.nanos, int64(elapsed))
+	if short {
+		atomic.AddInt64(&s.shorts, 1)
+	}
+}
+
+func (j *adaptiveJunction) sampled() {
+	if atomic.AddUint64(&j.samples, 1)%j.reorderEvery != 0 {
+		return
+	}
+	// 同一时刻只有一个 goroutine 重排，其余继续使用旧顺序
+	if !atomic.CompareAndSwapInt32(&j.reordering, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&j.reordering, 0)
+	j.order.Store(j.plan())
+}
+
+func (j *adaptiveJunction) plan() *junctionOrder {
+	// 以固定的子条件为屏障分段，段内按 耗时/短路概率 升序排列；统计减半衰减以跟随流量变化
+	n := len(j.children)
+	scores := make([]float64, n)
+	for i := 0; i < n; i++ {
+		s := &j.stats[i]
+		calls := atomic.LoadInt64(&s.calls)
+		shorts := atomic.LoadInt64(&s.shorts)
+		nanos := atomic.LoadInt64(&s.nanos)
+		if calls > 0 {
+			// 平滑短路概率，未被采样到的子条件耗时记为 0，下一轮优先执行以获得采样
+			scores[i] = float64(nanos) / float64(calls) / (float64(shorts+1) / float64(calls+2))
+		}
+		atomic.AddInt64(&s.calls, -calls/2)
+		atomic.AddInt64(&s.shorts, -shorts/2)
+		atomic.AddInt64(&s.nanos, -nanos/2)
+	}
+	index := make([]int, 0, n)
+	start := 0
+	flush := func(end int) {
+		segment := make([]int, 0, end-start)
+		for i := start; i < end; i++ {
+			segment = append(segment, i)
+		}
+		sort.SliceStable(segment, func(a, b int) bool {
+			return scores[segment[a]] < scores[segment[b]]
+		})
+		index = append(index, segment...)
+	}
+	for i := 0; i < n; i++ {
+		if !j.movable[i] {
+			flush(i)
+			index = append(index, i)
+			start = i + 1
+		}
+	}
+	flush(n)
+	identity := true
+	for i, v := range index {
+		if i != v {
+			identity = false
+			break
+		}
+	}
+	return &junctionOrder{index: index, identity: identity}
+}
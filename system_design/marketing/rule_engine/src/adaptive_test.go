@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// reorderTestRule 中 b、c、d 可移动，a 的数值比较遇到字符串会出错，固定在原位
+func reorderTestRule() []Rule {
+	return []Rule{activeRule("R", 0, and(
+		leaf("eq", "b", "x"),
+		leaf("gt", "a", 5),
+		leaf("in", "c", []interface{}{1, 2}),
+		leaf("eq", "d", true),
+	))}
+}
+
+func TestAdaptiveOrderingKeepsFallibleChildrenInPlace(t *testing.T) {
+	// d 总是不满足，预热后会被重排到可移动段的首位；a 仍然排在 b 之后、c 与 d 之前，原顺序下的错误照常返回
+	engine := NewEngine(reorderTestRule(), WithAdaptiveOrdering(AdaptiveConfig{SampleEvery: 1, ReorderEvery: 1}), WithoutRuleIndex())
+	for i := 0; i < 256; i++ {
+		if _, err := engine.Evaluate(context.Background(), NewFact(map[string]interface{}{"a": 10, "b": "x", "c": 1, "d": false})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := engine.Evaluate(context.Background(), NewFact(map[string]interface{}{"a": "oops", "b": "x", "c": 1, "d": false}))
+	if err == nil {
+		t.Fatal("expected left is not number")
+	}
+	results, err := engine.Evaluate(context.Background(), NewFact(map[string]interface{}{"a": "oops", "b": "y", "c": 1, "d": false}))
+	if err != nil || len(results) != 0 {
+		t.Fatalf("b short-circuits before a = %v, %v", results, err)
+	}
+}
+
+func TestAdaptiveOrderingMatchesStatic(t *testing.T) {
+	static := NewEngine(reorderTestRule())
+	adaptive := NewEngine(reorderTestRule(), WithAdaptiveOrdering(AdaptiveConfig{SampleEvery: 1, ReorderEvery: 4}))
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				var a interface{} = (i + g) % 10
+				if i%17 == 3 {
+					a = "oops"
+				}
+				data := map[string]interface{}{"a": a, "b": []string{"x", "x", "y"}[(i*g)%3], "c": i % 4, "d": i%5 != 0}
+				want, wantErr := static.Evaluate(context.Background(), NewFact(data))
+				got, err := adaptive.Evaluate(context.Background(), NewFact(data))
+				if (err == nil) != (wantErr == nil) || !reflect.DeepEqual(resultIDs(got), resultIDs(want)) {
+					errs <- fmt.Errorf("data %v: adaptive = %v, %v; static = %v, %v", data, resultIDs(got), err, resultIDs(want), wantErr)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func TestAdaptivePlanKeepsFixedChildren(t *testing.T) {
+	nodes := make([]compiledNode, 5)
+	for i := range nodes {
+		nodes[i] = compiledNode{eval: alwaysTrue, movable: i != 2}
+	}
+	junction := newAdaptiveJunction(false, nodes, AdaptiveConfig{SampleEvery: 1 << 20, ReorderEvery: 1 << 20})
+	// 越靠后的子条件越便宜，段内倒序；固定的子条件 2 不跨越
+	for i := range junction.stats {
+		junction.stats[i] = childStats{calls: 10, shorts: 5, nanos: int64(100 - i*10)}
+	}
+	if got := junction.plan().index; !reflect.DeepEqual(got, []int{1, 0, 2, 4, 3}) {
+		t.Fatalf("plan = %v", got)
+	}
+}
+
+func TestAdaptiveOrderingKeepsLoaderOrder(t *testing.T) {
+	// profile.level 在预热流量中直接给出，vip 总是不满足并被重排到前面；
+	// 注册了 loader 的事实按原顺序执行，loader 的错误不会被 vip 短路掩盖
+	rules := []Rule{activeRule("R", 0, and(leaf("eq", "profile.level", 3), leaf("eq", "vip", true)))}
+	engine := NewEngine(rules, WithAdaptiveOrdering(AdaptiveConfig{SampleEvery: 1, ReorderEvery: 1}), WithoutRuleIndex())
+	for i := 0; i < 256; i++ {
+		data := map[string]interface{}{"profile": map[string]interface{}{"level": 3}, "vip": false}
+		if _, err := engine.Evaluate(context.Background(), NewFact(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	errProfile := errors.New("profile down")
+	for i := 0; i < 16; i++ {
+		fact := NewFact(map[string]interface{}{"vip": false})
+		fact.SetLoader("profile.level", func() (interface{}, error) {
+			return nil, errProfile
+		})
+		if _, err := engine.Evaluate(context.Background(), fact); !errors.Is(err, errProfile) {
+			t.Fatalf("evaluation %d: err = %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// BatchResult 为批量评估中单个事实的结果
+type BatchResult struct {
+	// Index 为事实在输入中的序号
+	Index   int
+	Results []Result
+	// Errors 为按 ErrorPolicy 跳过的规则错误，Err 为导致该事实评估失败的错误
+	Errors []RuleFailure
+	Err    error
+}
+
+// BatchOption 定制批量评估的并发与输出顺序
+type BatchOption func(*batchOptions)
+
+type batchOptions struct {
+	// 同时评估的事实数上限
+	workers int
+	// 流式评估按完成顺序输出，不再按输入顺序重排
+	unordered bool
+}
+
+func newBatchOptions(opts []BatchOption) batchOptions {
+	options := batchOptions{workers: runtime.GOMAXPROCS(0)}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	if options.workers <= 0 {
+		options.workers = 1
+	}
+	return options
+}
+
+// WithWorkers 设置 worker 数量，即同时评估的事实数上限，默认为 GOMAXPROCS
+func WithWorkers(n int) BatchOption {
+	return func(o *batchOptions) {
+		o.workers = n
+	}
+}
+
+// WithUnordered 让 EvaluateStream 按完成顺序输出结果，慢事实不再阻塞后续结果；
+// 调用方通过 BatchResult.Index 对应输入
+func WithUnordered() BatchOption {
+	return func(o *batchOptions) {
+		o.unordered = true
+	}
+}
+
+var errNilFact = errors.New("fact is nil")
+
+// resultChunkFacts 为一个结果分配块可容纳的事实数，按近期最大命中数计算块大小
+const resultChunkFacts = 64
+
+// ruleScratch 为同一 worker 连续评估多个事实时复用的缓冲
+type ruleScratch struct {
+	mutexHit map[string]bool
+	// 结果分配块：各事实的结果切片依次从块中切出，多个事实共用一次分配。
+	// 交给调用方的切片容量等于长度，追加时自行扩容，不会覆盖相邻事实的结果
+	chunk []Result
+	// 近期单个事实的最大命中数，即每个事实预留的块空间
+	hits int
+}
+
+func (s *ruleScratch) reset() map[string]bool {
+	if s.mutexHit == nil {
+		s.mutexHit = map[string]bool{}
+	}
+	clear(s.mutexHit)
+	return s.mutexHit
+}
+
+func (s *ruleScratch) results() []Result {
+	// 从分配块中切出下一个事实的结果切片；尚无命中记录时不预留，第一条命中时再分配
+	if s.hits == 0 {
+		return nil
+	}
+	if cap(s.chunk)-len(s.chunk) < s.hits {
+		s.chunk = make([]Result, 0, resultChunkFacts*s.hits)
+	}
+	start := len(s.chunk)
+	return s.chunk[start : start : start+s.hits]
+}
+
+func (s *ruleScratch) commit(results []Result) []Result {
+	// 登记事实实际使用的块空间，返回容量收紧后的结果切片；超出预留空间时结果已另行分配，块空间留给下一个事实
+	if len(results) > s.hits {
+		s.hits = len(results)
+		return results[:len(results):len(results)]
+	}
+	s.chunk = s.chunk[:len(s.chunk)+len(results)]
+	return results[:len(results):len(results)]
+}
+
+func (e *Engine) evaluateBatchFact(fact *Fact, scratch *ruleScratch) (result BatchResult) {
+	// 与 Evaluate 语义一致：预取、按引擎执行模式执行规则，上下文结束按错误返回；
+	// 评估中的 panic（如事实访问器）转为该事实的错误，worker 继续处理其余事实。
+	// 未提交的结果留在分配块的空闲空间中，由下一个事实覆盖
+	defer func() {
+		if r := recover(); r != nil {
+			result = BatchResult{Err: fmt.Errorf("evaluate panic: %v", r)}
+		}
+	}()
+	if fact == nil {
+		return BatchResult{Err: errNilFact}
+	}
+	fact = e.prefetch(fact)
+	run, err := e.runRulesWith(e.rules, fact, e.options.mode, scratch)
+	if err == nil {
+		err = run.stopped
+	}
+	if err != nil {
+		return BatchResult{Err: err}
+	}
+	return BatchResult{Results: scratch.commit(run.results), Errors: run.errors}
+}
+
+// EvaluateBatch 使用有限的 worker 评估一批事实，返回结果与输入一一对应；
+// 单个事实出错只影响该事实的 BatchResult
+func (e *Engine) EvaluateBatch(facts []*Fact, opts ...BatchOption) []BatchResult {
+	options := newBatchOptions(opts)
+	results := make([]BatchResult, len(facts))
+	workers := options.workers
+	if workers > len(facts) {
+		workers = len(facts)
+	}
+	var (
+		next int64 = -1
+		wg   sync.WaitGroup
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var scratch ruleScratch
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(facts) {
+					return
+				}
+				result := e.evaluateBatchFact(facts[i], &scratch)
+				result.Index = i
+				results[i] = result
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// BatchIterator 逐个读取 EvaluateStream 的结果：
+//
+//	it := engine.EvaluateStream(ctx, facts)
+//	defer it.Close()
+//	for it.Next() {
+//		result := it.Result()
+//	}
+//	if err := it.Err(); err != nil { ... }
+type BatchIterator struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	results <-chan BatchResult
+	current BatchResult
+	closed  bool
+	// 后台 goroutine 因 ctx 结束而提前退出
+	interrupted int32
+}
+
+// Next 等待下一个结果，输入读完或 ctx 结束后返回 false
+func (it *BatchIterator) Next() bool {
+	if it.closed {
+		return false
+	}
+	result, ok := <-it.results
+	if !ok {
+		return false
+	}
+	it.current = result
+	return true
+}
+
+// Result 返回 Next 读到的结果
+func (it *BatchIterator) Result() BatchResult {
+	return it.current
+}
+
+// Err 返回提前结束的原因；输入全部评估完成或由 Close 结束时为 nil
+func (it *BatchIterator) Err() error {
+	if it.closed || atomic.LoadInt32(&it.interrupted) == 0 {
+		return nil
+	}
+	return it.ctx.Err()
+}
+
+// Close 停止读取输入与评估，并等待后台 goroutine 退出
+func (it *BatchIterator) Close() {
+	if it.closed {
+		return
+	}
+	it.closed = true
+	it.cancel()
+	for range it.results {
+	}
+}
+
+type batchJob struct {
+	index int
+	fact  *Fact
+}
+
+// EvaluateStream 从 facts 读取事实并由有限的 worker 并发评估，默认按输入顺序输出结果。
+// 同时处于评估中或等待输出的事实不超过 2 倍 worker 数，调用方读取缓慢时自动限流；
+// 事实绑定 ctx，ctx 结束后停止读取输入，Next 在已输出的结果之后返回 false
+func (e *Engine) EvaluateStream(ctx context.Context, facts <-chan *Fact, opts ...BatchOption) *BatchIterator {
+	options := newBatchOptions(opts)
+	it := &BatchIterator{ctx: ctx}
+	ctx, it.cancel = context.WithCancel(ctx)
+	interrupt := func() {
+		atomic.StoreInt32(&it.interrupted, 1)
+	}
+	window := make(chan struct{}, 2*options.workers)
+	jobs := make(chan batchJob)
+	done := make(chan BatchResult, options.workers)
+	out := make(chan BatchResult)
+	it.results = out
+
+	// 分发：占用窗口后再交给 worker，窗口在结果输出后释放
+	go func() {
+		defer close(jobs)
+		for index := 0; ; index++ {
+			var (
+				fact *Fact
+				ok   bool
+			)
+			select {
+			case fact, ok = <-facts:
+			case <-ctx.Done():
+				interrupt()
+				return
+			}
+			if !ok {
+				return
+			}
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				interrupt()
+				return
+			}
+			select {
+			case jobs <- batchJob{index: index, fact: fact}:
+			case <-ctx.Done():
+				interrupt()
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < options.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var scratch ruleScratch
+			for job := range jobs {
+				fact := job.fact
+				if fact != nil {
+					fact = fact.WithContext(ctx)
+				}
+				result := e.evaluateBatchFact(fact, &scratch)
+				result.Index = job.index
+				select {
+				case done <- result:
+				case <-ctx.Done():
+					interrupt()
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// 汇总：有序模式下暂存先完成的结果，按序号依次输出；ctx 结束后不再输出，
+	// 排空 worker 仍在发送的结果并等待它们退出后关闭 out
+	go func() {
+		defer close(out)
+		defer func() {
+			for range done {
+			}
+		}()
+		pending := map[int]BatchResult{}
+		next := 0
+		emit := func(result BatchResult) bool {
+			select {
+			case out <- result:
+				<-window
+				return true
+			case <-ctx.Done():
+				interrupt()
+				return false
+			}
+		}
+		for result := range done {
+			if options.unordered {
+				if !emit(result) {
+					return
+				}
+				continue
+			}
+			pending[result.Index] = result
+			for {
+				ready, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				if !emit(ready) {
+					return
+				}
+				next++
+			}
+		}
+	}()
+	return it
+}
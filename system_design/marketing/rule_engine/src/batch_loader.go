@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+// BatchLoader 一次调用加载同一前缀下的多个子字段，返回 子字段名 -> 值，未返回的子字段视为不存在
+type BatchLoader func(ctx context.Context, keys []string) (map[string]interface{}, error)
+
+// batchGroup 为注册在同一前缀上的批量 loader
+type batchGroup struct {
+	prefix string
+	keys   []string
+	// 子字段名 -> 规范化后的完整路径
+	paths map[string]string
+	load  BatchLoader
+	// 超时与降级配置，降级值作用于每个请求的子字段
+	config factLoader
+}
+
+// SetBatchLoader 为 prefix 下的 keys 注册批量 loader。
+// prefix 节点缺失时一次加载全部 keys 并创建该节点；节点已存在但缺少部分子字段时，
+// 一次补齐全部缺失的子字段；预取时只请求规则实际引用的子字段。
+func (f *Fact) SetBatchLoader(prefix string, keys []string, loader BatchLoader, opts ...LoaderOption) {
+	parsed, err := parseFactPath(prefix)
+	if err != nil {
+		// 非法前缀无法被任何路径访问，与 SetLoader 一致不报错
+		return
+	}
+	group := &batchGroup{
+		prefix: formatSegments(parsed.segments),
+		keys:   append([]string(nil), keys...),
+		paths:  make(map[string]string, len(keys)),
+		load:   loader,
+	}
+	sort.Strings(group.keys)
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&group.config)
+		}
+	}
+	for _, key := range group.keys {
+		segments := append(append([]pathSegment(nil), parsed.segments...), pathSegment{kind: segmentKey, key: key})
+		group.paths[key] = formatSegments(segments)
+		f.setLoader(group.paths[key], &factLoader{batch: group, key: key})
+	}
+	f.setLoader(group.prefix, &factLoader{batch: group})
+}
+
+func (g *batchGroup) run(ctx context.Context, keys []string) (map[string]interface{}, error) {
+	// 复用单个 loader 的超时与取消逻辑，失败时按子字段填充降级值；确认不存在时与单个 loader 一致按缺失处理
+	l := factLoader{
+		load: func(ctx context.Context) (interface{}, error) {
+			return g.load(ctx, keys)
+		},
+		timeout: g.config.timeout,
+	}
+	val, err := l.run(ctx, g.prefix)
+	if err != nil {
+		if !g.config.hasFallback || ctx.Err() != nil || errors.Is(err, ErrFactNotFound) {
+			return nil, err
+		}
+		fallback := make(map[string]interface{}, len(keys))
+		for _, key := range keys {
+			fallback[key] = g.config.fallback
+		}
+		return fallback, nil
+	}
+	values, _ := val.(map[string]interface{})
+	return values, nil
+}
+
+// batchValue 为批量结果中单个路径的取值，absent 表示后端未返回该子字段
+type batchValue struct {
+	val    interface{}
+	absent bool
+}
+
+func (g *batchGroup) resolve(ctx context.Context, members map[string]*factLoader) (map[string]batchValue, error) {
+	// members 为本次需要加载的路径；请求前缀节点时加载全部子字段
+	keys := make([]string, 0, len(members))
+	whole := false
+	for _, member := range members {
+		if member.key == "" {
+			whole = true
+			break
+		}
+		keys = append(keys, member.key)
+	}
+	if whole {
+		keys = g.keys
+	}
+	sort.Strings(keys)
+	values, err := g.run(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	resolved := make(map[string]batchValue, len(members))
+	for keyPath, member := range members {
+		if member.key == "" {
+			node := make(map[string]interface{}, len(values))
+			for k, v := range values {
+				node[k] = v
+			}
+			resolved[keyPath] = batchValue{val: node}
+			continue
+		}
+		v, ok := values[member.key]
+		resolved[keyPath] = batchValue{val: v, absent: !ok}
+	}
+	return resolved, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// profileBackend 记录每次批量调用请求的子字段
+type profileBackend struct {
+	mu    sync.Mutex
+	calls [][]string
+	err   error
+}
+
+func (b *profileBackend) load(ctx context.Context, keys []string) (map[string]interface{}, error) {
+	b.mu.Lock()
+	b.calls = append(b.calls, append([]string(nil), keys...))
+	b.mu.Unlock()
+	if b.err != nil {
+		return nil, b.err
+	}
+	values := map[string]interface{}{}
+	for _, key := range keys {
+		switch key {
+		case "level_mask":
+			values[key] = LevelMaskGold
+		case "tags":
+			values[key] = []interface{}{UserTagHighValue}
+		case "push_enabled":
+			values[key] = true
+		}
+	}
+	return values, nil
+}
+
+var profileKeys = []string{"level_mask", "tags", "push_enabled", "nickname"}
+
+func TestBatchLoaderCoalescesGetPath(t *testing.T) {
+	backend := &profileBackend{}
+	fact := NewFact(nil)
+	fact.SetBatchLoader("user", profileKeys, backend.load)
+	for _, path := range []string{"user.level_mask", "user.tags", "user.push_enabled"} {
+		if _, ok, err := fact.GetPath(path); !ok || err != nil {
+			t.Fatalf("GetPath(%s) = %v, %v", path, ok, err)
+		}
+	}
+	// 后端未返回的子字段按缺失处理，且不再重复请求
+	if _, ok, err := fact.GetPath("user.nickname"); ok || err != nil {
+		t.Fatalf("nickname = %v, %v", ok, err)
+	}
+	if len(backend.calls) != 1 {
+		t.Fatalf("backend called %d times: %v", len(backend.calls), backend.calls)
+	}
+	if !reflect.DeepEqual(backend.calls[0], []string{"level_mask", "nickname", "push_enabled", "tags"}) {
+		t.Fatalf("requested keys = %v", backend.calls[0])
+	}
+}
+
+func TestBatchLoaderFillsMissingKeysOnly(t *testing.T) {
+	backend := &profileBackend{}
+	fact := NewFact(map[string]interface{}{"user": map[string]interface{}{"level_mask": LevelMaskDiamond}})
+	fact.SetBatchLoader("user", profileKeys, backend.load)
+	if v, _, _ := fact.GetPath("user.level_mask"); v != LevelMaskDiamond {
+		t.Fatalf("provided value overwritten: %v", v)
+	}
+	if _, ok, err := fact.GetPath("user.tags"); !ok || err != nil {
+		t.Fatalf("tags = %v, %v", ok, err)
+	}
+	if len(backend.calls) != 1 || !reflect.DeepEqual(backend.calls[0], []string{"nickname", "push_enabled", "tags"}) {
+		t.Fatalf("calls = %v", backend.calls)
+	}
+}
+
+func TestBatchLoaderPrefetchRequestsReferencedKeys(t *testing.T) {
+	// 前缀节点已存在时，预取只补齐规则引用的子字段
+	backend := &profileBackend{}
+	fact := NewFact(map[string]interface{}{"user": map[string]interface{}{}})
+	fact.SetBatchLoader("user", profileKeys, backend.load)
+	engine := NewEngine(levelRule(), WithPrefetch(0))
+	results, err := engine.Evaluate(context.Background(), fact)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("results = %v, %v", results, err)
+	}
+	if len(backend.calls) != 1 || !reflect.DeepEqual(backend.calls[0], []string{"level_mask"}) {
+		t.Fatalf("calls = %v", backend.calls)
+	}
+}
+
+func TestBatchLoaderWholePrefix(t *testing.T) {
+	backend := &profileBackend{}
+	fact := NewFact(nil)
+	fact.SetBatchLoader("user", profileKeys, backend.load)
+	v, ok, err := fact.GetPath("user")
+	if !ok || err != nil {
+		t.Fatalf("GetPath(user) = %v, %v", ok, err)
+	}
+	if user := v.(map[string]interface{}); len(user) != 3 {
+		t.Fatalf("user = %v", user)
+	}
+	if _, _, err := fact.GetPath("user.tags"); err != nil || len(backend.calls) != 1 {
+		t.Fatalf("calls = %v, err = %v", backend.calls, err)
+	}
+}
+
+func TestBatchLoaderFallbackAndNotFound(t *testing.T) {
+	backend := &profileBackend{err: errors.New("backend down")}
+	fact := NewFact(nil)
+	fact.SetBatchLoader("user", profileKeys, backend.load, WithLoaderFallback(0))
+	if v, ok, err := fact.GetPath("user.level_mask"); !ok || err != nil || v != 0 {
+		t.Fatalf("fallback = %v, %v, %v", v, ok, err)
+	}
+
+	backend = &profileBackend{err: ErrFactNotFound}
+	fact = NewFact(nil)
+	fact.SetBatchLoader("user", profileKeys, backend.load, WithLoaderFallback(0))
+	if _, ok, err := fact.GetPath("user.level_mask"); ok || err != nil {
+		t.Fatalf("not found = %v, %v", ok, err)
+	}
+}
+
+func TestBatchLoaderConcurrentAccess(t *testing.T) {
+	// go test -race：并发访问同一前缀下的不同子字段
+	backend := &profileBackend{}
+	fact := NewFact(nil)
+	fact.SetBatchLoader("user", profileKeys, backend.load)
+	var wg sync.WaitGroup
+	for _, path := range []string{"user.level_mask", "user.tags", "user.push_enabled", "user.level_mask"} {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			if _, ok, err := fact.GetPath(path); !ok || err != nil {
+				t.Errorf("GetPath(%s) = %v, %v", path, ok, err)
+			}
+		}(path)
+	}
+	wg.Wait()
+}
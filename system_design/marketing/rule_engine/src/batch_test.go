@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func batchRules() []Rule {
+	rules := []Rule{
+		activeRule("BIG", 4, leaf("gt", "amount", 100)),
+		activeRule("SMALL", 3, leaf("gt", "amount", 10)),
+		activeRule("VIP", 2, leaf("eq", "vip", true)),
+		activeRule("ANY", 1, leaf("ne", "vip", "none")),
+	}
+	rules[0].MutexGroup, rules[1].MutexGroup = "discount", "discount"
+	return rules
+}
+
+func batchFacts(n int) []*Fact {
+	facts := make([]*Fact, n)
+	for i := range facts {
+		var amount interface{} = i * 7 % 200
+		if i%13 == 5 {
+			amount = "oops"
+		}
+		facts[i] = NewFact(map[string]interface{}{"amount": amount, "vip": i%3 == 0})
+	}
+	return facts
+}
+
+func TestEvaluateBatchMatchesEvaluate(t *testing.T) {
+	engine := NewEngine(batchRules())
+	facts := batchFacts(300)
+	facts[17] = nil
+	for _, workers := range []int{1, 4, 64} {
+		results := engine.EvaluateBatch(facts, WithWorkers(workers))
+		if len(results) != len(facts) {
+			t.Fatalf("workers %d: %d results", workers, len(results))
+		}
+		for i, result := range results {
+			if result.Index != i {
+				t.Fatalf("result %d has index %d", i, result.Index)
+			}
+			if facts[i] == nil {
+				if !errors.Is(result.Err, errNilFact) {
+					t.Fatalf("nil fact err = %v", result.Err)
+				}
+				continue
+			}
+			want, err := engine.Evaluate(context.Background(), facts[i])
+			if fmt.Sprint(err) != fmt.Sprint(result.Err) || !reflect.DeepEqual(resultIDs(result.Results), resultIDs(want)) {
+				t.Fatalf("fact %d: batch = %v, %v; evaluate = %v, %v", i, resultIDs(result.Results), result.Err, resultIDs(want), err)
+			}
+		}
+	}
+}
+
+func TestEvaluateBatchResultsDoNotAlias(t *testing.T) {
+	// 同一 worker 的结果切自同一分配块，调用方追加不能覆盖相邻事实的结果
+	engine := NewEngine(batchRules())
+	results := engine.EvaluateBatch(batchFacts(40), WithWorkers(1))
+	snapshot := make([][]string, len(results))
+	for i, result := range results {
+		snapshot[i] = resultIDs(result.Results)
+	}
+	for i := range results {
+		results[i].Results = append(results[i].Results, Result{RuleID: "APPENDED"})
+	}
+	for i, result := range results {
+		if got := resultIDs(result.Results[:len(result.Results)-1]); !reflect.DeepEqual(got, snapshot[i]) {
+			t.Fatalf("fact %d results overwritten: %v, want %v", i, got, snapshot[i])
+		}
+	}
+}
+
+func TestRuleScratchSharesChunk(t *testing.T) {
+	var scratch ruleScratch
+	if scratch.results() != nil {
+		t.Fatal("no chunk before the first hit")
+	}
+	first := scratch.commit(append(scratch.results(), Result{RuleID: "A"}, Result{RuleID: "B"}))
+	if scratch.hits != 2 || cap(first) != 2 {
+		t.Fatalf("hits = %d, cap = %d", scratch.hits, cap(first))
+	}
+	second := scratch.commit(append(scratch.results(), Result{RuleID: "C"}))
+	third := scratch.commit(append(scratch.results(), Result{RuleID: "D"}, Result{RuleID: "E"}))
+	if &scratch.chunk[0] != &second[0] || &scratch.chunk[1] != &third[0] {
+		t.Fatal("consecutive facts should be carved from the same chunk")
+	}
+	if cap(second) != 1 || second[0].RuleID != "C" || third[0].RuleID != "D" {
+		t.Fatalf("second = %v, third = %v", second, third)
+	}
+	// 超出预留空间时结果另行分配，预留空间随之扩大
+	grown := scratch.commit(append(scratch.results(), Result{}, Result{}, Result{}))
+	if len(grown) != 3 || scratch.hits != 3 {
+		t.Fatalf("grown = %d, hits = %d", len(grown), scratch.hits)
+	}
+}
+
+func streamFacts(facts []*Fact) <-chan *Fact {
+	ch := make(chan *Fact)
+	go func() {
+		defer close(ch)
+		for _, fact := range facts {
+			ch <- fact
+		}
+	}()
+	return ch
+}
+
+// panickingAccessor 读取任意字段时 panic
+type panickingAccessor struct{}
+
+func (panickingAccessor) GetField(string) (interface{}, bool) {
+	panic("accessor broken")
+}
+
+func TestEvaluateBatchRecoversPanic(t *testing.T) {
+	// 单个事实评估 panic 只写入该事实的 Err，同一 worker 上的其余事实照常评估
+	engine := NewEngine(batchRules())
+	facts := batchFacts(8)
+	want := engine.EvaluateBatch(facts)
+	facts[3] = NewStructFact(panickingAccessor{})
+	check := func(name string, result BatchResult) {
+		if result.Index == 3 {
+			if result.Err == nil || !strings.Contains(result.Err.Error(), "evaluate panic: accessor broken") {
+				t.Fatalf("%s: panicking fact = %+v", name, result)
+			}
+			return
+		}
+		if !reflect.DeepEqual(resultIDs(result.Results), resultIDs(want[result.Index].Results)) {
+			t.Fatalf("%s: result %d = %+v, want %+v", name, result.Index, result, want[result.Index])
+		}
+	}
+	for _, result := range engine.EvaluateBatch(facts, WithWorkers(1)) {
+		check("batch", result)
+	}
+	it := engine.EvaluateStream(context.Background(), streamFacts(facts), WithWorkers(1))
+	defer it.Close()
+	n := 0
+	for it.Next() {
+		check("stream", it.Result())
+		n++
+	}
+	if n != len(facts) || it.Err() != nil {
+		t.Fatalf("read %d results, err = %v", n, it.Err())
+	}
+}
+
+func TestEvaluateStreamOrdered(t *testing.T) {
+	engine := NewEngine(batchRules())
+	facts := batchFacts(200)
+	want := engine.EvaluateBatch(facts)
+	it := engine.EvaluateStream(context.Background(), streamFacts(facts), WithWorkers(4))
+	defer it.Close()
+	i := 0
+	for it.Next() {
+		result := it.Result()
+		if result.Index != i || !reflect.DeepEqual(resultIDs(result.Results), resultIDs(want[i].Results)) {
+			t.Fatalf("result %d = %+v, want %+v", i, result, want[i])
+		}
+		i++
+	}
+	if i != len(facts) || it.Err() != nil {
+		t.Fatalf("read %d results, err = %v", i, it.Err())
+	}
+}
+
+func TestEvaluateStreamUnordered(t *testing.T) {
+	engine := NewEngine(batchRules())
+	facts := batchFacts(200)
+	it := engine.EvaluateStream(context.Background(), streamFacts(facts), WithWorkers(8), WithUnordered())
+	defer it.Close()
+	seen := map[int]bool{}
+	for it.Next() {
+		seen[it.Result().Index] = true
+	}
+	if len(seen) != len(facts) || it.Err() != nil {
+		t.Fatalf("seen %d results, err = %v", len(seen), it.Err())
+	}
+}
+
+func TestEvaluateStreamBoundsInFlightFacts(t *testing.T) {
+	// 调用方不读取时，从输入读取的事实不超过窗口大小（2 倍 worker 数）加正在分发的一个
+	engine := NewEngine(batchRules())
+	var sent int64
+	facts := make(chan *Fact)
+	go func() {
+		for _, fact := range batchFacts(100) {
+			facts <- fact
+			atomic.AddInt64(&sent, 1)
+		}
+		close(facts)
+	}()
+	it := engine.EvaluateStream(context.Background(), facts, WithWorkers(2))
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt64(&sent); n > 5 {
+		t.Fatalf("read %d facts without a reader", n)
+	}
+	count := 0
+	for it.Next() {
+		count++
+	}
+	if count != 100 {
+		t.Fatalf("read %d results", count)
+	}
+}
+
+func TestEvaluateStreamCanceledWhileBlocked(t *testing.T) {
+	// ctx 在汇总阻塞于输出时结束：Next 返回 false，Err 为上下文错误，后台 goroutine 全部退出
+	for _, unordered := range []bool{false, true} {
+		engine := NewEngine(batchRules())
+		ctx, cancel := context.WithCancel(context.Background())
+		opts := []BatchOption{WithWorkers(4)}
+		if unordered {
+			opts = append(opts, WithUnordered())
+		}
+		facts := make(chan *Fact)
+		go func() {
+			for _, fact := range batchFacts(1000) {
+				select {
+				case facts <- fact:
+				case <-time.After(time.Second):
+					return
+				}
+			}
+		}()
+		it := engine.EvaluateStream(ctx, facts, opts...)
+		if !it.Next() {
+			t.Fatal("expected a first result")
+		}
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+		closed := make(chan struct{})
+		go func() {
+			for it.Next() {
+			}
+			close(closed)
+		}()
+		select {
+		case <-closed:
+		case <-time.After(2 * time.Second):
+			t.Fatal("iterator did not finish after cancel")
+		}
+		if !errors.Is(it.Err(), context.Canceled) {
+			t.Fatalf("unordered %v: err = %v", unordered, it.Err())
+		}
+		it.Close()
+	}
+}
+
+func TestEvaluateStreamCloseEarly(t *testing.T) {
+	engine := NewEngine(batchRules())
+	it := engine.EvaluateStream(context.Background(), streamFacts(batchFacts(500)), WithWorkers(4))
+	if !it.Next() {
+		t.Fatal("expected a result")
+	}
+	it.Close()
+	if it.Next() || it.Err() != nil {
+		t.Fatalf("after Close: err = %v", it.Err())
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// 性能基准：go test -run '^$' -bench . -benchmem
+
+func BenchmarkEngineEvaluate(b *testing.B) {
+	// 默认规则集上单次评估的耗时与内存分配
+	engine := NewEngine(LoadRules())
+	fact := benchmarkFact()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := engine.Evaluate(context.Background(), fact); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEvaluateConditionInterpreted(b *testing.B) {
+	rules := LoadRules()
+	fact := benchmarkFact()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for j := range rules {
+			if _, err := EvaluateCondition(rules[j].Condition, fact); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkEngineEvaluateParallelLarge(b *testing.B) {
+	// 大事实上的并行评估：各组共享数据树，只读的规则不再复制任何节点
+	engine := NewEngine(LoadRules())
+	large := largeBenchmarkFact()
+	groupByType := func(rule Rule) string { return rule.Type }
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := engine.EvaluateParallel(large, groupByType); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEngineEvaluateSkewed(b *testing.B) {
+	// 几乎总是通过的等值叶子排在总是不满足的叶子之前：自适应重排后后者先执行并短路
+	skewed := []Rule{{RuleID: "RULE_SKEWED", Condition: &Condition{Operator: "AND", Children: []Condition{
+		{Operator: "ne", Field: "reco.scene", Value: "none"},
+		{Operator: "in", Field: "user.level_mask", Value: []interface{}{LevelMaskGold, LevelMaskDiamond}},
+		{Operator: "eq", Field: "risk.user_blacklist", Value: false},
+		{Operator: "eq", Field: "task.first_order", Value: true},
+	}}}}
+	large := largeBenchmarkFact()
+	for _, c := range []struct {
+		name   string
+		engine *Engine
+	}{
+		{"static", NewEngine(skewed)},
+		{"adaptive", NewEngine(skewed, WithAdaptiveOrdering(AdaptiveConfig{}))},
+	} {
+		engine := c.engine
+		b.Run(c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := engine.Evaluate(context.Background(), large); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEngineEvaluate10k(b *testing.B) {
+	// 1 万条按场景区分的规则：索引先按 reco.scene 取出候选规则，只执行命中场景的 10 条
+	many := manyBenchmarkRules(10000, 1000)
+	fact := benchmarkFact()
+	for _, c := range []struct {
+		name   string
+		engine *Engine
+	}{
+		{"unindexed", NewEngine(many, WithoutRuleIndex())},
+		{"indexed", NewEngine(many)},
+	} {
+		engine := c.engine
+		b.Run(c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := engine.Evaluate(context.Background(), fact); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEvaluate1k(b *testing.B) {
+	// 批量评估 1000 个事实：顺序逐个评估与 worker 池批量评估对比
+	engine := NewEngine(LoadRules())
+	batch := make([]*Fact, 1000)
+	for i := range batch {
+		batch[i] = benchmarkFact()
+	}
+	b.Run("sequential", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, fact := range batch {
+				if _, err := engine.Evaluate(context.Background(), fact); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, result := range engine.EvaluateBatch(batch) {
+				if result.Err != nil {
+					b.Fatal(result.Err)
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkFactCloneLarge(b *testing.B) {
+	// 公开 Clone 深拷贝数据树，即改为共享之前并行评估每组的复制开销
+	large := largeBenchmarkFact()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		large.Clone()
+	}
+}
+
+func BenchmarkFactShareLarge(b *testing.B) {
+	// 并行评估的分组副本共享数据树，只复制加载状态
+	large := largeBenchmarkFact()
+	calls := newEvaluationLoadGroup()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		large.share(calls)
+	}
+}
+
+func benchmarkFact() *Fact {
+	return NewFact(benchmarkData())
+}
+
+func benchmarkData() map[string]interface{} {
+	// 覆盖默认规则集引用的全部字段
+	return map[string]interface{}{
+		"user": map[string]interface{}{
+			"register_days":  5,
+			"level_mask":     LevelMaskGold,
+			"push_enabled":   false,
+			"phone_verified": true,
+		},
+		"cart": map[string]interface{}{
+			"total_amount": 320,
+			"threshold":    150,
+			"coupons_mask": CouponMaskPlatform + CouponMaskFullReduction,
+			"items": []interface{}{
+				map[string]interface{}{"sku": "SKU_TISSUE", "category": "日用", "price": 25},
+				map[string]interface{}{"sku": "SKU_APPLE", "category": ItemCategoryFresh, "price": 68},
+			},
+		},
+		"risk": map[string]interface{}{
+			"daily_coupon_count": 1,
+			"user_blacklist":     false,
+			"device_blacklist":   false,
+		},
+		"task": map[string]interface{}{
+			"checkin_streak":    7,
+			"profile_completed": true,
+			"first_order":       false,
+		},
+		"touch": map[string]interface{}{"message_count_24h": 1},
+		"reco":  map[string]interface{}{"scene": RecoSceneBigPromo, "merchant_score": 4.5},
+		"after": map[string]interface{}{"credit_score": 720, "refund_amount": 100, "delivery_delay_minutes": 10},
+	}
+}
+
+func largeBenchmarkFact() *Fact {
+	return NewFact(largeBenchmarkData())
+}
+
+func largeBenchmarkData() map[string]interface{} {
+	// 在默认事实上附加 1000 件商品与 500 条订单历史，模拟大请求
+	data := benchmarkData()
+	items := make([]interface{}, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		items = append(items, map[string]interface{}{"sku": fmt.Sprintf("SKU_%d", i), "category": "日用", "price": i % 100})
+	}
+	data["cart"].(map[string]interface{})["items"] = items
+	orders := make([]interface{}, 0, 500)
+	for i := 0; i < 500; i++ {
+		orders = append(orders, map[string]interface{}{"order_id": i, "amount": i * 3, "tags": []interface{}{"a", "b"}})
+	}
+	data["history"] = map[string]interface{}{"orders": orders}
+	return data
+}
+
+func manyBenchmarkRules(n, scenes int) []Rule {
+	// 生成 n 条推荐规则，均匀分布在 scenes 个场景上，默认事实的场景 big_promo 对应其中一个
+	rules := make([]Rule, 0, n)
+	for i := 0; i < n; i++ {
+		scene := fmt.Sprintf("scene_%d", i%scenes)
+		if i%scenes == 0 {
+			scene = RecoSceneBigPromo
+		}
+		rules = append(rules, Rule{
+			RuleID:   fmt.Sprintf("RULE_RECO_%d", i),
+			Type:     RuleTypeReco,
+			Priority: n - i,
+			Condition: &Condition{Operator: "AND", Children: []Condition{
+				{Operator: "eq", Field: "reco.scene", Value: scene},
+				{Operator: "gte", Field: "reco.merchant_score", Value: float64(i%50) / 10},
+			}},
+			Actions: []Action{{Type: ActionRecoInsert, Params: map[string]interface{}{"item": RecoItemMainVenue}}},
+		})
+	}
+	return rules
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCloneIsolatesCallerMutations(t *testing.T) {
+	data := map[string]interface{}{
+		"user": map[string]interface{}{"city": UserCityBeijing},
+		"cart": map[string]interface{}{"items": []interface{}{map[string]interface{}{"price": 10}}},
+	}
+	fact := NewFact(data)
+	cloned := fact.Clone()
+	// 修改输入数据与原 Fact 取到的值，都不会影响克隆
+	data["user"].(map[string]interface{})["city"] = UserCityShanghai
+	v, _, _ := fact.GetPath("cart.items")
+	v.([]interface{})[0].(map[string]interface{})["price"] = 99
+	if v, _, _ := cloned.GetPath("user.city"); v != UserCityBeijing {
+		t.Fatalf("clone city = %v", v)
+	}
+	if v, _, _ := cloned.GetPath("cart.items[0].price"); v != 10 {
+		t.Fatalf("clone price = %v", v)
+	}
+	// 反方向同样隔离
+	v, _, _ = cloned.GetPath("user")
+	v.(map[string]interface{})["level_mask"] = LevelMaskGold
+	if _, ok, _ := fact.GetPath("user.level_mask"); ok {
+		t.Fatal("clone mutation leaked into the original")
+	}
+}
+
+func TestCloneWritesAreIndependent(t *testing.T) {
+	fact := NewFact(map[string]interface{}{"user": map[string]interface{}{"city": UserCityBeijing}})
+	cloned := fact.Clone()
+	if err := cloned.SetPath("user.city", UserCityShanghai); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fact.DeletePath("user.city"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _, _ := cloned.GetPath("user.city"); v != UserCityShanghai {
+		t.Fatalf("clone city = %v", v)
+	}
+	if _, ok, _ := fact.GetPath("user.city"); ok {
+		t.Fatal("original still has city")
+	}
+}
+
+func TestSharedGroupFactsAreIndependent(t *testing.T) {
+	// go test -race：并行评估的分组副本共享数据树，各自的加载与写入互不影响
+	data := largeBenchmarkData()
+	fact := NewFact(data)
+	fact.SetLoader("user.city", func() (interface{}, error) { return UserCityBeijing, nil })
+	calls := newEvaluationLoadGroup()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		group := fact.share(calls)
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, _, err := group.GetPath("user.city"); err != nil || v != UserCityBeijing {
+				t.Errorf("city = %v, %v", v, err)
+			}
+			group.SetPath("cart.items[3].price", i)
+			if v, _, _ := group.GetPath("cart.items[3].price"); v != i {
+				t.Errorf("group %d price = %v", i, v)
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _, _ := fact.GetPath("cart.items[3].price"); v != 3 {
+		t.Fatalf("original price = %v", v)
+	}
+	if !reflect.DeepEqual(data, largeBenchmarkData()) {
+		t.Fatal("input data mutated")
+	}
+}
+
+func TestEvaluateParallelMatchesSequential(t *testing.T) {
+	engine := NewEngine(LoadRules())
+	groupByType := func(rule Rule) string { return rule.Type }
+	sequential, err := engine.Evaluate(context.Background(), largeBenchmarkFact())
+	if err != nil {
+		t.Fatal(err)
+	}
+	parallel, err := engine.EvaluateParallel(largeBenchmarkFact(), groupByType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parallel) != len(sequential) {
+		t.Fatalf("parallel = %d results, sequential = %d", len(parallel), len(sequential))
+	}
+}
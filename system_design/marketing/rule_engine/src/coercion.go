@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CoercionPolicy 决定左右值类型不一致时的处理方式
+type CoercionPolicy int
+
+const (
+	// CoercionStrict 不做类型转换：字符串与数值视为不等，数值比较遇到字符串报错
+	CoercionStrict CoercionPolicy = iota
+	// CoercionLenient 将数值字符串解析为数值、将 "true"/"false" 解析为布尔值后再比较
+	CoercionLenient
+	// CoercionError 字符串、数值、布尔之间的类型不一致一律报错
+	CoercionError
+)
+
+const (
+	kindNumber = "number"
+	kindString = "string"
+	kindBool   = "bool"
+	kindOther  = "other"
+)
+
+func scalarKind(v interface{}) string {
+	// 识别参与类型转换的标量类别，json.Number 视为数值
+	switch v.(type) {
+	case string:
+		return kindString
+	case bool:
+		return kindBool
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, json.Number, Decimal:
+		return kindNumber
+	default:
+		return kindOther
+	}
+}
+
+func parseScalar(s string, kind string) (interface{}, bool) {
+	// 将字符串解析为目标类别，数值保留原文以免损失精度；
+	// 只接受有限的十进制写法，NaN、Inf 与十六进制等不视为数值
+	s = strings.TrimSpace(s)
+	switch kind {
+	case kindNumber:
+		if !validDecimal(s) {
+			return nil, false
+		}
+		if f, err := strconv.ParseFloat(s, 64); err != nil || math.IsInf(f, 0) {
+			return nil, false
+		}
+		return json.Number(s), true
+	case kindBool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, false
+		}
+		return b, true
+	default:
+		return nil, false
+	}
+}
+
+func (c comparator) coercePair(left, right interface{}) (interface{}, interface{}, string, error) {
+	// 等值类比较前对齐左右值类型，返回转换说明用于评估轨迹
+	lk, rk := scalarKind(left), scalarKind(right)
+	if lk == rk || lk == kindOther || rk == kindOther {
+		return left, right, "", nil
+	}
+	switch c.coercion {
+	case CoercionLenient:
+		if lk == kindString {
+			if v, ok := parseScalar(left.(string), rk); ok {
+				return v, right, "left " + kindString + "->" + rk, nil
+			}
+		}
+		if rk == kindString {
+			if v, ok := parseScalar(right.(string), lk); ok {
+				return left, v, "right " + kindString + "->" + lk, nil
+			}
+		}
+	case CoercionError:
+		return left, right, "", fmt.Errorf("type mismatch: %s vs %s", lk, rk)
+	}
+	return left, right, "", nil
+}
+
+func (c comparator) coerceNumber(side string, v interface{}) (interface{}, string) {
+	// 数值类比较前尝试将字符串解析为数值，仅宽松策略生效
+	s, ok := v.(string)
+	if !ok || c.coercion != CoercionLenient {
+		return v, ""
+	}
+	n, ok := parseScalar(s, kindNumber)
+	if !ok {
+		return v, ""
+	}
+	return n, side + " " + kindString + "->" + kindNumber
+}
+
+func (c comparator) coerceText(side string, v interface{}) (string, string, error) {
+	// 字符串包含前将另一侧的数值或布尔值转为文本，仅宽松策略生效
+	if s, ok := v.(string); ok {
+		return s, "", nil
+	}
+	kind := scalarKind(v)
+	switch {
+	case kind == kindOther:
+	case c.coercion == CoercionLenient:
+		if s, ok := formatScalar(v); ok {
+			return s, side + " " + kind + "->" + kindString, nil
+		}
+	case c.coercion == CoercionError:
+		return "", "", fmt.Errorf("type mismatch: %s vs %s", kindString, kind)
+	}
+	return "", "", errors.New(side + " is not string for contains")
+}
+
+func formatScalar(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case bool:
+		return strconv.FormatBool(t), true
+	case json.Number:
+		return t.String(), true
+	case Decimal:
+		return t.String(), true
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(t), 'f', -1, 32), true
+	}
+	if i, ok := toInt64(v); ok {
+		return strconv.FormatInt(i, 10), true
+	}
+	if u, ok := toUint64(v); ok {
+		return strconv.FormatUint(u, 10), true
+	}
+	return "", false
+}
+
+func joinNotes(a, b string) string {
+	// 合并转换说明，列表逐元素比较时去除重复项
+	if a == "" {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+	for _, note := range strings.Split(a, ", ") {
+		if note == b {
+			return a
+		}
+	}
+	return a + ", " + b
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestCoercionPolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   CoercionPolicy
+		operator string
+		left     interface{}
+		right    interface{}
+		want     bool
+		wantErr  bool
+	}{
+		{"strict string eq number", CoercionStrict, "eq", "300", 300, false, false},
+		{"lenient string eq number", CoercionLenient, "eq", "300", 300, true, false},
+		{"error string eq number", CoercionError, "eq", "300", 300, false, true},
+		{"strict string gte", CoercionStrict, "gte", "300", 300, false, true},
+		{"lenient string gte", CoercionLenient, "gte", "300.5", 300, true, false},
+		{"lenient bool", CoercionLenient, "eq", "true", true, true, false},
+		{"lenient in", CoercionLenient, "in", "2", []interface{}{1, 2}, true, false},
+		{"lenient list contains", CoercionLenient, "contains", []interface{}{"1", "2"}, 2, true, false},
+		{"strict string contains number", CoercionStrict, "contains", "SKU300", 300, false, true},
+		{"lenient string contains number", CoercionLenient, "contains", "SKU300", 300, true, false},
+		{"lenient string contains bool", CoercionLenient, "contains", "is_true", true, true, false},
+		{"error string contains number", CoercionError, "contains", "SKU300", 300, false, true},
+		{"lenient string contains list", CoercionLenient, "contains", "SKU300", []interface{}{300}, false, true},
+		{"lenient NaN", CoercionLenient, "gt", "NaN", 0, false, true},
+		{"lenient Inf", CoercionLenient, "gt", "Inf", 0, false, true},
+		{"lenient infinity", CoercionLenient, "gt", "+infinity", 0, false, true},
+		{"lenient overflow", CoercionLenient, "gt", "1e400", 0, false, true},
+		{"lenient hex", CoercionLenient, "eq", "0x10", 16, false, false},
+		{"lenient NaN eq", CoercionLenient, "eq", "NaN", json.Number("1"), false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmp := comparator{coercion: tt.policy, decimal: DefaultDecimalConfig}
+			got, _, err := applyOperator(cmp, tt.operator, tt.left, tt.right)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoercionAppliesToAllEvaluationPaths(t *testing.T) {
+	condition := &Condition{Operator: "gte", Field: "cart.total_amount", Value: 300}
+	data := map[string]interface{}{"cart": map[string]interface{}{"total_amount": "300"}}
+	rules := []Rule{{RuleID: "R", Status: RuleStatusActive, Condition: condition}}
+
+	if _, err := EvaluateCondition(condition, NewFact(data)); err == nil {
+		t.Fatal("strict interpreted should fail on numeric string")
+	}
+	if ok, err := EvaluateCondition(condition, NewFact(data), WithCoercion(CoercionLenient)); err != nil || !ok {
+		t.Fatalf("lenient interpreted = %v, %v", ok, err)
+	}
+	results, err := NewEngine(rules, WithCoercion(CoercionLenient)).Evaluate(context.Background(), NewFact(data))
+	if err != nil || len(results) != 1 {
+		t.Fatalf("lenient engine = %v, %v", results, err)
+	}
+	results, err = NewReteEngine(rules, WithCoercion(CoercionLenient)).Evaluate(NewFact(data))
+	if err != nil || len(results) != 1 {
+		t.Fatalf("lenient rete = %v, %v", results, err)
+	}
+}
+
+func TestCoercionTrace(t *testing.T) {
+	rules := []Rule{{RuleID: "R", Status: RuleStatusActive, Condition: &Condition{Operator: "contains", Field: "sku", Value: 300}}}
+	engine := NewEngine(rules, WithCoercion(CoercionLenient))
+	results, trace, err := engine.EvaluateWithTrace(NewFact(map[string]interface{}{"sku": "SKU300"}))
+	if err != nil || len(results) != 1 {
+		t.Fatalf("results = %v, %v", results, err)
+	}
+	if len(trace.Coercions) != 1 {
+		t.Fatalf("coercions = %+v", trace.Coercions)
+	}
+	record := trace.Coercions[0]
+	if record.RuleID != "R" || record.Field != "sku" || record.Note != "right number->string" {
+		t.Fatalf("coercion record = %+v", record)
+	}
+}
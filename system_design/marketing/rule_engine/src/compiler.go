@@ -0,0 +1,528 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// conditionCompiler 持有编译期确定的比较语义，并将条件树特化为闭包
+type conditionCompiler struct {
+	cmp comparator
+	// 字段级与操作符级字符串归一化器
+	fieldNormalizers    map[string]*stringNormalizer
+	operatorNormalizers map[string]*stringNormalizer
+	// 非空时 AND/OR 按运行期统计自适应重排子条件
+	adaptive *AdaptiveConfig
+}
+
+var defaultCompiler = conditionCompiler{cmp: defaultComparator}
+
+func newConditionCompiler(options engineOptions) conditionCompiler {
+	compiler := conditionCompiler{cmp: options.comparator(), adaptive: options.adaptive}
+	if len(options.fieldNormalization) > 0 {
+		compiler.fieldNormalizers = make(map[string]*stringNormalizer, len(options.fieldNormalization))
+		for field, config := range options.fieldNormalization {
+			compiler.fieldNormalizers[field] = newStringNormalizer(config)
+		}
+	}
+	if len(options.operatorNormalization) > 0 {
+		compiler.operatorNormalizers = make(map[string]*stringNormalizer, len(options.operatorNormalization))
+		for operator, config := range options.operatorNormalization {
+			compiler.operatorNormalizers[operator] = newStringNormalizer(config)
+		}
+	}
+	return compiler
+}
+
+func (c conditionCompiler) normalizerFor(field, operator string) *stringNormalizer {
+	if !normalizedOperator(operator) {
+		return nil
+	}
+	if n, ok := c.fieldNormalizers[field]; ok {
+		return n
+	}
+	return c.operatorNormalizers[operator]
+}
+
+// compiledNode 为条件节点的编译产物，constant 为真时结果在编译期已确定
+type compiledNode struct {
+	eval     func(*Fact) (bool, error)
+	constant bool
+	value    bool
+	// movable 为真时节点是只读取 path 的叶子，除 path 上的 loader 外不会出错，自适应重排只移动这类子节点
+	movable bool
+	path    factPath
+}
+
+func alwaysTrue(*Fact) (bool, error) {
+	return true, nil
+}
+
+func alwaysFalse(*Fact) (bool, error) {
+	return false, nil
+}
+
+func constantNode(value bool) compiledNode {
+	if value {
+		return compiledNode{eval: alwaysTrue, constant: true, value: true}
+	}
+	return compiledNode{eval: alwaysFalse, constant: true, value: false}
+}
+
+func (c conditionCompiler) compile(condition *Condition) (func(*Fact) (bool, error), error) {
+	node, err := c.compileNode(condition)
+	if err != nil {
+		return nil, err
+	}
+	return node.eval, nil
+}
+
+func (c conditionCompiler) compileNode(condition *Condition) (compiledNode, error) {
+	// 非常量节点挂上轨迹钩子，轨迹模式与普通评估共用同一份编译结果
+	node, err := c.compileCondition(condition)
+	if err != nil || node.constant {
+		return node, err
+	}
+	node.eval = traceable(condition, node.eval)
+	return node, nil
+}
+
+func (c conditionCompiler) compileCondition(condition *Condition) (compiledNode, error) {
+	if condition == nil {
+		return constantNode(true), nil
+	}
+	op := strings.ToUpper(condition.Operator)
+	switch op {
+	case "AND":
+		return c.compileJunction(op, condition)
+	case "OR":
+		return c.compileJunction(op, condition)
+	case "NOT":
+		if len(condition.Children) != 1 {
+			return compiledNode{}, errors.New("NOT requires exactly one child")
+		}
+		child, err := c.compileNode(&condition.Children[0])
+		if err != nil {
+			return compiledNode{}, err
+		}
+		if child.constant {
+			return constantNode(!child.value), nil
+		}
+		eval := child.eval
+		return compiledNode{eval: func(fact *Fact) (bool, error) {
+			ok, err := eval(fact)
+			if err != nil {
+				return false, err
+			}
+			return !ok, nil
+		}}, nil
+	case ConditionAny, ConditionAll, ConditionNone:
+		if err := validateQuantifier(op, condition); err != nil {
+			return compiledNode{}, err
+		}
+		child, err := c.compileNode(&condition.Children[0])
+		if err != nil {
+			return compiledNode{}, err
+		}
+		path, err := newFactPath(condition.Field)
+		if err != nil {
+			return compiledNode{}, err
+		}
+		eval := child.eval
+		return compiledNode{eval: func(fact *Fact) (bool, error) {
+			if fact.traceRule != nil {
+				return evaluateQuantifier(op, path, fact, tracedElements(fact, eval))
+			}
+			return evaluateQuantifier(op, path, fact, eval)
+		}}, nil
+	default:
+		// 叶子节点条件编译
+		return c.compileLeaf(condition)
+	}
+}
+
+func (c conditionCompiler) compileJunction(op string, condition *Condition) (compiledNode, error) {
+	// AND/OR 编译：剔除不影响结果的常量子节点，截断短路后不可达的分支
+	if len(condition.Children) == 0 {
+		return compiledNode{}, errors.New(op + " requires children")
+	}
+	// AND 遇到恒假短路，OR 遇到恒真短路
+	shortCircuit := op == "OR"
+	children := make([]func(*Fact) (bool, error), 0, len(condition.Children))
+	nodes := make([]compiledNode, 0, len(condition.Children))
+	folded := false
+	for i := range condition.Children {
+		// 短路之后的分支不可达，但仍需编译以保证非法条件照常报错
+		node, err := c.compileNode(&condition.Children[i])
+		if err != nil {
+			return compiledNode{}, err
+		}
+		if folded {
+			continue
+		}
+		if node.constant {
+			if node.value != shortCircuit {
+				// 不影响结果的常量子节点直接剔除
+				continue
+			}
+			folded = true
+			if len(children) == 0 {
+				continue
+			}
+		}
+		children = append(children, node.eval)
+		nodes = append(nodes, node)
+	}
+	switch {
+	case len(children) == 0 && folded:
+		return constantNode(shortCircuit), nil
+	case len(children) == 0:
+		return constantNode(!shortCircuit), nil
+	case len(children) == 1 && !folded:
+		return compiledNode{eval: children[0]}, nil
+	}
+	if c.adaptive != nil && reorderable(nodes) {
+		return compiledNode{eval: newAdaptiveJunction(shortCircuit, nodes, *c.adaptive).eval}, nil
+	}
+	return compiledNode{eval: func(fact *Fact) (bool, error) {
+		for _, fn := range children {
+			ok, err := fn(fact)
+			if err != nil {
+				return false, err
+			}
+			if ok == shortCircuit {
+				return shortCircuit, nil
+			}
+		}
+		return !shortCircuit, nil
+	}}, nil
+}
+
+// leafOp 为特化后的比较函数，右值已在编译期归一化
+type leafOp func(left interface{}) (bool, string, error)
+
+func (c conditionCompiler) compileLeaf(condition *Condition) (compiledNode, error) {
+	// 编译单条比较条件：预切分路径、预选操作符并预处理常量右值
+	if condition.Field == "" {
+		return compiledNode{}, errors.New("leaf condition requires field")
+	}
+	field := condition.Field
+	path, err := newFactPath(field)
+	if err != nil {
+		return compiledNode{}, err
+	}
+	operator := strings.ToLower(condition.Operator)
+	cmp := c.cmp
+	norm := c.normalizerFor(field, operator)
+	if ref, ok := varRef(condition.Value); ok {
+		// 右值引用事实字段，运行期取值后走通用比较
+		refPath, err := newFactPath(ref)
+		if err != nil {
+			return compiledNode{}, err
+		}
+		return compiledNode{eval: func(fact *Fact) (bool, error) {
+			left, ok, err := fact.getPath(path)
+			if err != nil || !ok {
+				if err == nil {
+					fact.traceMissing()
+				}
+				return false, err
+			}
+			right, ok, err := fact.getPath(refPath)
+			if err != nil {
+				return false, err
+			}
+			if !ok {
+				return false, errors.New("variable not found: " + ref)
+			}
+			if norm != nil {
+				left, right = norm.value(left), norm.value(right)
+			}
+			matched, note, err := applyOperator(cmp, operator, left, right)
+			if note != "" {
+				fact.recordCoercion(field, operator, left, right, note)
+			}
+			fact.traceOperands(left, right, note)
+			return matched, err
+		}}, nil
+	}
+	right := condition.Value
+	if norm != nil {
+		// 常量右值在编译期完成归一化
+		right = norm.value(right)
+	}
+	op := c.compileOperator(operator, right)
+	return compiledNode{movable: c.infallible(operator, right), path: path, eval: func(fact *Fact) (bool, error) {
+		left, ok, err := fact.getPath(path)
+		if err != nil || !ok {
+			if err == nil {
+				fact.traceMissing()
+			}
+			return false, err
+		}
+		if norm != nil {
+			left = norm.value(left)
+		}
+		matched, note, err := op(left)
+		if note != "" {
+			fact.recordCoercion(field, operator, left, right, note)
+		}
+		fact.traceOperands(left, right, note)
+		return matched, err
+	}}, nil
+}
+
+// infallible 判断常量叶子取到左值后是否不会出错：浮点与严格类型模式下 eq/ne 与常量列表上的 in 只做等值判断；
+// 数值比较遇到非数值左值时报错，不在此列
+func (c conditionCompiler) infallible(operator string, right interface{}) bool {
+	if c.cmp.numeric != NumericFloat || c.cmp.coercion != CoercionStrict {
+		return false
+	}
+	switch operator {
+	case "eq", "ne":
+		return true
+	case "in":
+		_, ok := compileInSet(right)
+		return ok
+	}
+	return false
+}
+
+func (c conditionCompiler) compileOperator(operator string, right interface{}) leafOp {
+	// 浮点与严格类型模式下按操作符特化，其余模式回退到通用比较
+	cmp := c.cmp
+	generic := func(left interface{}) (bool, string, error) {
+		return applyOperator(cmp, operator, left, right)
+	}
+	if cmp.numeric != NumericFloat || cmp.coercion != CoercionStrict {
+		return generic
+	}
+	switch operator {
+	case "eq":
+		equal := compileEquals(right)
+		return func(left interface{}) (bool, string, error) {
+			return equal(left), "", nil
+		}
+	case "ne":
+		equal := compileEquals(right)
+		return func(left interface{}) (bool, string, error) {
+			return !equal(left), "", nil
+		}
+	case "gt", "gte", "lt", "lte":
+		rf, ok := toFloat(right)
+		if !ok || math.IsNaN(rf) {
+			// 非法常量保持运行期报错行为
+			return generic
+		}
+		if ri, ok := toInt64(right); ok {
+			return compileIntCompare(operator, ri, compileNumberCompare(operator, rf, generic))
+		}
+		return compileNumberCompare(operator, rf, generic)
+	case "in":
+		set, ok := compileInSet(right)
+		if !ok {
+			return generic
+		}
+		return func(left interface{}) (bool, string, error) {
+			return set.contains(left), "", nil
+		}
+	case "contains":
+		r, ok := right.(string)
+		if !ok {
+			return generic
+		}
+		return func(left interface{}) (bool, string, error) {
+			if l, ok := left.(string); ok {
+				return strings.Contains(l, r), "", nil
+			}
+			return generic(left)
+		}
+	case "bitmask_all":
+		rv, ok := toUint64(right)
+		if !ok {
+			return generic
+		}
+		return func(left interface{}) (bool, string, error) {
+			lv, ok := toUint64(left)
+			if !ok {
+				return false, "", errBitmaskLeft
+			}
+			return lv&rv == rv, "", nil
+		}
+	default:
+		return generic
+	}
+}
+
+func compileNumberCompare(operator string, rf float64, generic leafOp) leafOp {
+	// 每个操作符独立闭包，运行期不再分派也不再分配比较函数
+	switch operator {
+	case "gt":
+		return func(left interface{}) (bool, string, error) {
+			lf, ok := toFloat(left)
+			if !ok || math.IsNaN(lf) {
+				return generic(left)
+			}
+			return lf > rf, "", nil
+		}
+	case "gte":
+		return func(left interface{}) (bool, string, error) {
+			lf, ok := toFloat(left)
+			if !ok || math.IsNaN(lf) {
+				return generic(left)
+			}
+			return lf >= rf, "", nil
+		}
+	case "lt":
+		return func(left interface{}) (bool, string, error) {
+			lf, ok := toFloat(left)
+			if !ok || math.IsNaN(lf) {
+				return generic(left)
+			}
+			return lf < rf, "", nil
+		}
+	default:
+		return func(left interface{}) (bool, string, error) {
+			lf, ok := toFloat(left)
+			if !ok || math.IsNaN(lf) {
+				return generic(left)
+			}
+			return lf <= rf, "", nil
+		}
+	}
+}
+
+func compileIntCompare(operator string, ri int64, fallback leafOp) leafOp {
+	// 常量为整数：整数左值精确比较，不受 float64 精度影响，其余左值走浮点特化
+	return func(left interface{}) (bool, string, error) {
+		li, ok := toInt64(left)
+		if !ok {
+			return fallback(left)
+		}
+		c := compareInt64(li, ri)
+		switch operator {
+		case "gt":
+			return c > 0, "", nil
+		case "gte":
+			return c >= 0, "", nil
+		case "lt":
+			return c < 0, "", nil
+		default:
+			return c <= 0, "", nil
+		}
+	}
+}
+
+func compileEquals(right interface{}) func(interface{}) bool {
+	// 常量右值预先归一化，常见标量走类型断言，避免反射比较
+	switch r := normalizeNumber(right).(type) {
+	case string:
+		return func(left interface{}) bool {
+			l, ok := left.(string)
+			return ok && l == r
+		}
+	case float64:
+		ri, rInt := toInt64(right)
+		return func(left interface{}) bool {
+			if rInt {
+				// 整数常量与整数左值精确比较，避免超过 2^53 的值在 float64 下相等
+				if li, ok := toInt64(left); ok {
+					return li == ri
+				}
+			}
+			l, ok := toFloat(left)
+			return ok && l == r
+		}
+	case bool:
+		return func(left interface{}) bool {
+			l, ok := left.(bool)
+			return ok && l == r
+		}
+	default:
+		return func(left interface{}) bool {
+			return reflect.DeepEqual(normalizeNumber(left), r)
+		}
+	}
+}
+
+// inSet 为 in 操作符的常量列表预构建查找表
+type inSet struct {
+	strings map[string]struct{}
+	// numbers 为全部数值常量，ints 为其中的整数常量，floats 为非整数类型的常量；
+	// 整数左值先与整数常量精确比较，避免超过 2^53 的值在 float64 下相等，与 compileEquals 一致
+	numbers map[float64]struct{}
+	ints    map[int64]struct{}
+	floats  map[float64]struct{}
+	bools   [2]bool
+	others  []interface{}
+}
+
+func compileInSet(right interface{}) (*inSet, bool) {
+	rv := reflect.ValueOf(right)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	set := &inSet{
+		strings: map[string]struct{}{},
+		numbers: map[float64]struct{}{},
+		ints:    map[int64]struct{}{},
+		floats:  map[float64]struct{}{},
+	}
+	for i := 0; i < rv.Len(); i++ {
+		element := rv.Index(i).Interface()
+		switch v := normalizeNumber(element).(type) {
+		case string:
+			set.strings[v] = struct{}{}
+		case float64:
+			set.numbers[v] = struct{}{}
+			if n, ok := toInt64(element); ok {
+				set.ints[n] = struct{}{}
+			} else {
+				set.floats[v] = struct{}{}
+			}
+		case bool:
+			if v {
+				set.bools[1] = true
+			} else {
+				set.bools[0] = true
+			}
+		default:
+			set.others = append(set.others, v)
+		}
+	}
+	return set, true
+}
+
+func (s *inSet) contains(left interface{}) bool {
+	switch l := left.(type) {
+	case string:
+		_, ok := s.strings[l]
+		return ok
+	case bool:
+		if l {
+			return s.bools[1]
+		}
+		return s.bools[0]
+	}
+	if n, ok := toInt64(left); ok {
+		if _, ok := s.ints[n]; ok {
+			return true
+		}
+		f, _ := toFloat(left)
+		_, ok := s.floats[f]
+		return ok
+	}
+	if f, ok := toFloat(left); ok {
+		_, ok := s.numbers[f]
+		return ok
+	}
+	normalized := normalizeNumber(left)
+	for _, other := range s.others {
+		if reflect.DeepEqual(normalized, other) {
+			return true
+		}
+	}
+	return false
+}
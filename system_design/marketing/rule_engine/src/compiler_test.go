@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCompiledMatchesInterpretedOnDefaultRules(t *testing.T) {
+	fact := benchmarkFact()
+	for _, rule := range LoadRules() {
+		compiled, err := CompileCondition(rule.Condition)
+		if err != nil {
+			t.Fatalf("%s: %v", rule.RuleID, err)
+		}
+		got, gotErr := compiled(fact)
+		want, wantErr := EvaluateCondition(rule.Condition, fact)
+		if got != want || (gotErr == nil) != (wantErr == nil) {
+			t.Fatalf("%s: compiled = %v, %v; interpreted = %v, %v", rule.RuleID, got, gotErr, want, wantErr)
+		}
+	}
+}
+
+func TestCompiledLeavesDoNotAllocate(t *testing.T) {
+	fact := benchmarkFact()
+	conditions := []*Condition{
+		{Operator: "eq", Field: "reco.scene", Value: RecoSceneBigPromo},
+		{Operator: "gte", Field: "cart.total_amount", Value: 300},
+		{Operator: "lt", Field: "reco.merchant_score", Value: 4.8},
+		{Operator: "in", Field: "reco.scene", Value: []interface{}{"a", RecoSceneBigPromo}},
+		{Operator: "bitmask_all", Field: "user.level_mask", Value: LevelMaskGold},
+		{Operator: "AND", Children: []Condition{
+			{Operator: "eq", Field: "user.phone_verified", Value: true},
+			{Operator: "NOT", Children: []Condition{{Operator: "eq", Field: "risk.user_blacklist", Value: true}}},
+		}},
+	}
+	for _, condition := range conditions {
+		eval, err := CompileCondition(condition)
+		if err != nil {
+			t.Fatal(err)
+		}
+		allocs := testing.AllocsPerRun(100, func() {
+			if ok, err := eval(fact); err != nil || !ok {
+				t.Fatalf("%+v = %v, %v", condition, ok, err)
+			}
+		})
+		if allocs != 0 {
+			t.Fatalf("%+v allocates %v times per call", condition, allocs)
+		}
+	}
+}
+
+func TestEngineEvaluateAllocations(t *testing.T) {
+	// 默认规则集的单次评估只为结果与互斥组记录分配
+	engine := NewEngine(LoadRules())
+	fact := benchmarkFact()
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := engine.Evaluate(context.Background(), fact); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs > 8 {
+		t.Fatalf("Evaluate allocates %v times", allocs)
+	}
+}
+
+func TestConstantFolding(t *testing.T) {
+	if node, err := defaultCompiler.compileNode(nil); err != nil || !node.constant || !node.value {
+		t.Fatalf("nil condition = %+v, %v; want constant true", node, err)
+	}
+	// 常量之后的分支仍需编译，非法条件照常报错
+	invalid := &Condition{Operator: "AND", Children: []Condition{
+		{Operator: "eq", Field: "a", Value: 1},
+		{Operator: "OR"},
+	}}
+	if _, err := CompileCondition(invalid); err == nil {
+		t.Fatal("expected error for empty OR")
+	}
+	for _, op := range []string{"TRUE", "FALSE"} {
+		if _, err := CompileCondition(&Condition{Operator: op}); err == nil {
+			t.Fatalf("%s should not be a supported operator", op)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGetPathResultNotMutatedByLaterLoads(t *testing.T) {
+	// go test -race：取到的 map 在锁外遍历，同时其他 goroutine 触发向同一节点写回的 loader
+	fact := NewFact(map[string]interface{}{"user": map[string]interface{}{"city": UserCityBeijing}})
+	fact.SetLoader("user.level_mask", func() (interface{}, error) { return LevelMaskGold, nil })
+	fact.SetLoader("user.tags", func() (interface{}, error) { return []interface{}{UserTagHighValue}, nil })
+	v, _, err := fact.GetPath("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := v.(map[string]interface{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			for range user {
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		fact.GetPath("user.level_mask")
+		fact.GetPath("user.tags")
+		fact.SetPath("user.city", UserCityShanghai)
+	}()
+	wg.Wait()
+	if len(user) != 1 || user["city"] != UserCityBeijing {
+		t.Fatalf("earlier result mutated: %v", user)
+	}
+	if v, _, _ := fact.GetPath("user.level_mask"); v != LevelMaskGold {
+		t.Fatalf("level_mask = %v", v)
+	}
+}
+
+func TestLoadGroupDoesNotRetainResults(t *testing.T) {
+	// 单飞只覆盖进行中的加载：加载结束后，另一个副本访问同一 loader 时重新执行
+	var calls int32
+	fact := NewFact(nil)
+	fact.SetLoader("user.level_mask", func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return LevelMaskGold, nil
+	})
+	cloned := fact.Clone()
+	fact.GetPath("user.level_mask")
+	cloned.GetPath("user.level_mask")
+	if calls != 2 {
+		t.Fatalf("loader called %d times", calls)
+	}
+	// 克隆之前已加载的值随数据复制，不再重新加载
+	fact.Clone().GetPath("user.level_mask")
+	if calls != 2 {
+		t.Fatalf("loaded value reloaded by clone: %d calls", calls)
+	}
+}
+
+func TestEvaluateParallelLoadsOncePerEvaluation(t *testing.T) {
+	// go test -race：各分组共享本次评估的加载组，同一 loader 只执行一次
+	var calls int32
+	fact := NewFact(nil)
+	fact.SetLoader("user.level_mask", func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return LevelMaskGold, nil
+	})
+	var rules []Rule
+	for _, id := range []string{"A", "B", "C", "D"} {
+		rules = append(rules, Rule{RuleID: id, Type: id, Status: RuleStatusActive, Condition: &Condition{
+			Operator: "bitmask_all", Field: "user.level_mask", Value: LevelMaskGold,
+		}})
+	}
+	groupByType := func(rule Rule) string { return rule.Type }
+	results, err := NewEngine(rules).EvaluateParallel(fact, groupByType)
+	if err != nil || len(results) != 4 || calls != 1 {
+		t.Fatalf("engine: results = %d, err = %v, calls = %d", len(results), err, calls)
+	}
+	results, err = NewReteEngine(rules).EvaluateParallel(fact, groupByType)
+	if err != nil || len(results) != 4 || calls != 2 {
+		t.Fatalf("rete: results = %d, err = %v, calls = %d", len(results), err, calls)
+	}
+}
+
+func TestConcurrentEnginesShareFact(t *testing.T) {
+	// go test -race：多个引擎同时评估同一 Fact，读取、加载与写回并发进行
+	fact := NewFact(benchmarkData())
+	var calls int32
+	fact.SetLoader("user.city", func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return UserCityBeijing, nil
+	})
+	fact.SetSubtreeLoader("task", func(context.Context) (interface{}, error) {
+		return map[string]interface{}{"level": 3}, nil
+	})
+	cityRule := []Rule{{RuleID: "CITY", Status: RuleStatusActive, Condition: &Condition{
+		Operator: "eq", Field: "user.city", Value: UserCityBeijing,
+	}}}
+	engines := []*Engine{NewEngine(LoadRules()), NewEngine(cityRule), NewEngine(cityRule, WithPrefetch(0))}
+	var wg sync.WaitGroup
+	for i := 0; i < 12; i++ {
+		engine := engines[i%len(engines)]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := engine.Evaluate(context.Background(), fact); err != nil {
+				t.Error(err)
+			}
+			fact.GetPath("task.level")
+			fact.Clone().GetPath("user")
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("loader called %d times", calls)
+	}
+}
+
+func TestPanickingLoaderReleasesSingleFlight(t *testing.T) {
+	// go test -race：loader panic 后进行中的条目被移除，等待者得到错误，下一次访问重新加载
+	var calls int32
+	release := make(chan struct{})
+	fact := NewFact(nil)
+	fact.SetLoader("user.a", func() (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-release
+			panic("boom")
+		}
+		return 1, nil
+	})
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := fact.GetPath("user.a")
+			errs <- err
+		}()
+	}
+	for atomic.LoadInt32(&calls) == 0 {
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+	close(errs)
+	failed := 0
+	for err := range errs {
+		if err != nil {
+			if !strings.Contains(err.Error(), "loader panic: boom") {
+				t.Fatalf("err = %v", err)
+			}
+			failed++
+		}
+	}
+	if failed == 0 {
+		t.Fatal("expected the panicking load to surface as an error")
+	}
+	if v, ok, err := fact.GetPath("user.a"); err != nil || !ok || v != 1 {
+		t.Fatalf("retry = %v, %v, %v", v, ok, err)
+	}
+}
@@ -15,6 +15,9 @@ const (
 
 	ConditionAnd        = "AND"
 	ConditionOr         = "OR"
+	ConditionAny        = "ANY"
+	ConditionAll        = "ALL"
+	ConditionNone       = "NONE"
 	ConditionEq         = "eq"
 	ConditionGt         = "gt"
 	ConditionGte        = "gte"
@@ -24,6 +27,9 @@ const (
 	ConditionContains   = "contains"
 	ConditionBitmaskAll = "bitmask_all"
 
+	// ElementSelfField 在量词子条件中引用非对象元素本身
+	ElementSelfField = "$"
+
 	LevelMaskGold    = 2
 	LevelMaskDiamond = 4
 	LevelKeyGold     = "gold"
@@ -37,6 +43,8 @@ const (
 	UserCityShanghai  = "上海"
 	RecoSceneBigPromo = "big_promo"
 
+	ItemCategoryFresh = "生鲜"
+
 	ActionBenefitSend    = "benefit_send"
 	ActionNotifyUser     = "notify_user"
 	ActionPriceDiscount  = "price_discount"
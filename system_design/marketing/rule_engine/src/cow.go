@@ -0,0 +1,104 @@
+package main
+
+// 路径替换：数据树中已经发布的 map 与 []interface{} 不会被原地修改。
+// 每次写入都把根节点到目标节点路径上的各层浅拷贝一份，在副本上修改后替换根节点，
+// 读者取到的节点、克隆与录制持有的旧根节点都保持不变，无需登记节点归属。
+// 结构体与类型化切片无法复制，经过它们之后的节点仍原地写入，调用方需自行避免并发访问。
+
+func (f *Fact) writable(route []pathSegment, create bool) map[string]interface{} {
+	// 调用方持有写锁；沿 route 从根节点下行，返回可写入的 map，路径已不存在或不是 map 时返回 nil。
+	// create 为真时创建缺失的中间 map。成功时根节点替换为新副本，失败时数据树不变
+	st := f.state
+	root := copyNode(st.data).(map[string]interface{})
+	var current interface{} = root
+	copyable := true
+	for i, segment := range route {
+		switch segment.kind {
+		case segmentWildcard:
+			return nil
+		case segmentIndex:
+			list, ok := current.([]interface{})
+			if !ok || !copyable {
+				rv, ok := listValue(current)
+				if !ok {
+					return nil
+				}
+				index, ok := listIndex(segment.index, rv.Len())
+				if !ok {
+					return nil
+				}
+				current = rv.Index(index).Interface()
+				copyable = false
+				continue
+			}
+			index, ok := listIndex(segment.index, len(list))
+			if !ok {
+				return nil
+			}
+			list[index] = copyNode(list[index])
+			current = list[index]
+			continue
+		}
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			val, ok := lookupField(current, segment.key)
+			if !ok {
+				return nil
+			}
+			current = val
+			copyable = false
+			continue
+		}
+		val, ok := m[segment.key]
+		if !ok {
+			if i == 0 && f.source != nil {
+				if val, ok = lookupField(f.source, segment.key); ok {
+					current = val
+					copyable = false
+					continue
+				}
+			}
+			if !create {
+				return nil
+			}
+			child := map[string]interface{}{}
+			m[segment.key] = child
+			current = child
+			continue
+		}
+		if copyable {
+			val = copyNode(val)
+			m[segment.key] = val
+		}
+		current = val
+	}
+	m, ok := current.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	st.data = root
+	return m
+}
+
+func copyNode(v interface{}) interface{} {
+	// 浅拷贝一层 map 或 []interface{}，其余值原样返回
+	switch t := v.(type) {
+	case map[string]interface{}:
+		dst := make(map[string]interface{}, len(t)+1)
+		for k, val := range t {
+			dst[k] = val
+		}
+		return dst
+	case []interface{}:
+		return append([]interface{}(nil), t...)
+	default:
+		return v
+	}
+}
+
+func listIndex(index, length int) (int, bool) {
+	if index < 0 {
+		index += length
+	}
+	return index, index >= 0 && index < length
+}
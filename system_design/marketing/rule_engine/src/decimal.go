@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strconv"
+)
+
+// NumericMode 决定数值比较使用的算术模型
+type NumericMode int
+
+const (
+	// NumericFloat 将数值统一转换为 float64 比较，保持历史行为
+	NumericFloat NumericMode = iota
+	// NumericDecimal 使用精确十进制比较，DecimalConfig 只用于折扣等计算结果的舍入
+	NumericDecimal
+)
+
+// RoundingMode 定义十进制舍入规则
+type RoundingMode int
+
+const (
+	// RoundHalfUp 四舍五入，距离相等时远离零
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven 银行家舍入，距离相等时取偶数
+	RoundHalfEven
+	// RoundDown 直接截断，向零取整
+	RoundDown
+)
+
+// DecimalConfig 描述十进制模式下计算结果的精度与舍入规则
+type DecimalConfig struct {
+	// Scale 保留的小数位数，金额以分为单位时取 2
+	Scale int
+	// Rounding 超出精度部分的舍入规则
+	Rounding RoundingMode
+}
+
+// DefaultDecimalConfig 以分为精度并四舍五入，适用于人民币金额
+var DefaultDecimalConfig = DecimalConfig{Scale: 2, Rounding: RoundHalfUp}
+
+// Decimal 表示任意精度的十进制数，零值为 0
+type Decimal struct {
+	value *big.Rat
+}
+
+const (
+	// 有效数字与指数的上限，避免 1e999999 之类的输入构造超大整数
+	maxDecimalDigits   = 256
+	maxDecimalExponent = 1024
+)
+
+// ParseDecimal 解析十进制字符串，如 "299.99"、"-1.5e3"；
+// 不接受分数、进制前缀与下划线，有效数字超过 256 位或指数绝对值超过 1024 时返回错误
+func ParseDecimal(s string) (Decimal, error) {
+	if !validDecimal(s) {
+		return Decimal{}, errors.New("invalid decimal: " + s)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Decimal{}, errors.New("invalid decimal: " + s)
+	}
+	return Decimal{value: r}, nil
+}
+
+func validDecimal(s string) bool {
+	// [+-]digits[.digits][(e|E)[+-]digits]，整数与小数部分至少有一位数字
+	i := 0
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	digits, dot := 0, false
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c >= '0' && c <= '9' {
+			digits++
+			continue
+		}
+		if c == '.' && !dot {
+			dot = true
+			continue
+		}
+		break
+	}
+	if digits == 0 || digits > maxDecimalDigits {
+		return false
+	}
+	if i == len(s) {
+		return true
+	}
+	if s[i] != 'e' && s[i] != 'E' {
+		return false
+	}
+	exponent, err := strconv.Atoi(s[i+1:])
+	if err != nil {
+		return false
+	}
+	return exponent >= -maxDecimalExponent && exponent <= maxDecimalExponent
+}
+
+// NewDecimalFromInt 以整数创建 Decimal
+func NewDecimalFromInt(v int64) Decimal {
+	return Decimal{value: new(big.Rat).SetInt64(v)}
+}
+
+func (d Decimal) rat() *big.Rat {
+	if d.value == nil {
+		return new(big.Rat)
+	}
+	return d.value
+}
+
+// Cmp 比较两个十进制数，返回 -1/0/1
+func (d Decimal) Cmp(other Decimal) int {
+	return d.rat().Cmp(other.rat())
+}
+
+// Add 返回 d + other 的精确结果
+func (d Decimal) Add(other Decimal) Decimal {
+	return Decimal{value: new(big.Rat).Add(d.rat(), other.rat())}
+}
+
+// Mul 返回 d * other 的精确结果
+func (d Decimal) Mul(other Decimal) Decimal {
+	return Decimal{value: new(big.Rat).Mul(d.rat(), other.rat())}
+}
+
+// Round 按给定小数位数与舍入规则取整
+func (d Decimal) Round(scale int, mode RoundingMode) Decimal {
+	if scale < 0 {
+		scale = 0
+	}
+	factor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+	scaled := new(big.Rat).Mul(d.rat(), new(big.Rat).SetInt(factor))
+	num, den := scaled.Num(), scaled.Denom()
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() != 0 && mode != RoundDown {
+		// 余数的两倍与分母比较，判断舍去部分是否过半
+		half := new(big.Int).Mul(new(big.Int).Abs(r), big.NewInt(2)).Cmp(den)
+		up := half > 0 || (half == 0 && (mode == RoundHalfUp || q.Bit(0) == 1))
+		if up {
+			if num.Sign() < 0 {
+				q.Sub(q, big.NewInt(1))
+			} else {
+				q.Add(q, big.NewInt(1))
+			}
+		}
+	}
+	return Decimal{value: new(big.Rat).SetFrac(q, factor)}
+}
+
+// Float64 返回最接近的 float64，仅用于展示或对接浮点接口
+func (d Decimal) Float64() float64 {
+	f, _ := d.rat().Float64()
+	return f
+}
+
+// String 以有限小数输出，无法有限表示时退化为分数形式
+func (d Decimal) String() string {
+	r := d.rat()
+	places, ok := decimalPlaces(r.Denom())
+	if !ok {
+		return r.String()
+	}
+	return r.FloatString(places)
+}
+
+// MarshalJSON 以字符串输出，避免经 float64 序列化丢失精度
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func decimalPlaces(den *big.Int) (int, bool) {
+	// 分母仅含 2 与 5 的因子时可以有限小数表示
+	rest := new(big.Int).Set(den)
+	twos, fives := 0, 0
+	two, five := big.NewInt(2), big.NewInt(5)
+	mod := new(big.Int)
+	for {
+		q, m := new(big.Int).QuoRem(rest, two, mod)
+		if m.Sign() != 0 {
+			break
+		}
+		rest = q
+		twos++
+	}
+	for {
+		q, m := new(big.Int).QuoRem(rest, five, mod)
+		if m.Sign() != 0 {
+			break
+		}
+		rest = q
+		fives++
+	}
+	if rest.Cmp(big.NewInt(1)) != 0 {
+		return 0, false
+	}
+	if twos > fives {
+		return twos, true
+	}
+	return fives, true
+}
+
+func toDecimal(v interface{}) (Decimal, bool) {
+	// 浮点数取最短十进制表示，避免引入二进制误差；指数形式避免极大或极小的值展开成超长数字
+	switch t := v.(type) {
+	case Decimal:
+		return t, true
+	case int:
+		return NewDecimalFromInt(int64(t)), true
+	case int8:
+		return NewDecimalFromInt(int64(t)), true
+	case int16:
+		return NewDecimalFromInt(int64(t)), true
+	case int32:
+		return NewDecimalFromInt(int64(t)), true
+	case int64:
+		return NewDecimalFromInt(t), true
+	case uint:
+		return Decimal{value: new(big.Rat).SetUint64(uint64(t))}, true
+	case uint8:
+		return Decimal{value: new(big.Rat).SetUint64(uint64(t))}, true
+	case uint16:
+		return Decimal{value: new(big.Rat).SetUint64(uint64(t))}, true
+	case uint32:
+		return Decimal{value: new(big.Rat).SetUint64(uint64(t))}, true
+	case uint64:
+		return Decimal{value: new(big.Rat).SetUint64(t)}, true
+	case float64:
+		d, err := ParseDecimal(strconv.FormatFloat(t, 'g', -1, 64))
+		return d, err == nil
+	case float32:
+		d, err := ParseDecimal(strconv.FormatFloat(float64(t), 'g', -1, 32))
+		return d, err == nil
+	case json.Number:
+		d, err := ParseDecimal(t.String())
+		return d, err == nil
+	default:
+		return Decimal{}, false
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDecimal(t *testing.T) {
+	valid := map[string]string{
+		"299.99":  "299.99",
+		"-1.5e3":  "-1500",
+		"+.5":     "0.5",
+		"5.":      "5",
+		"1E-2":    "0.01",
+		"0010.10": "10.1",
+	}
+	for input, want := range valid {
+		d, err := ParseDecimal(input)
+		if err != nil {
+			t.Fatalf("ParseDecimal(%q): %v", input, err)
+		}
+		if d.String() != want {
+			t.Fatalf("ParseDecimal(%q) = %s, want %s", input, d, want)
+		}
+	}
+	invalid := []string{"", "-", ".", "1/3", "0x10", "0b1", "1_000", "1e", "1e+", "1.2.3", "NaN", "Inf", "1e999999", "1e-1025",
+		strings.Repeat("9", maxDecimalDigits+1)}
+	for _, input := range invalid {
+		if _, err := ParseDecimal(input); err == nil {
+			t.Fatalf("ParseDecimal(%q) should fail", input)
+		}
+	}
+}
+
+func TestParseDecimalRejectsHugeExponentQuickly(t *testing.T) {
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		if _, ok := toDecimal(json.Number("1e999999")); ok {
+			t.Fatal("huge exponent should not parse")
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("rejecting huge exponents took %v", elapsed)
+	}
+}
+
+func TestToDecimalFloatExtremes(t *testing.T) {
+	for _, f := range []float64{1e300, 5e-324, -1.7976931348623157e308} {
+		if _, ok := toDecimal(f); !ok {
+			t.Fatalf("toDecimal(%g) failed", f)
+		}
+	}
+}
+
+func TestDecimalComparisonsAreExact(t *testing.T) {
+	cmp := comparator{numeric: NumericDecimal, decimal: DecimalConfig{Scale: 2, Rounding: RoundHalfUp}}
+	tests := []struct {
+		operator    string
+		left, right interface{}
+		want        bool
+	}{
+		{"gte", json.Number("299.995"), 300, false},
+		{"lt", json.Number("299.999999999"), 300, true},
+		{"eq", json.Number("1.005"), json.Number("1.01"), false},
+		{"eq", json.Number("300.00"), 300, true},
+		{"eq", 0.1, json.Number("0.1"), true},
+		{"gt", json.Number("300.001"), 300, true},
+	}
+	for _, tt := range tests {
+		got, _, err := applyOperator(cmp, tt.operator, tt.left, tt.right)
+		if err != nil {
+			t.Fatalf("%v %s %v: %v", tt.left, tt.operator, tt.right, err)
+		}
+		if got != tt.want {
+			t.Fatalf("%v %s %v = %v, want %v", tt.left, tt.operator, tt.right, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalRounding(t *testing.T) {
+	tests := []struct {
+		input string
+		mode  RoundingMode
+		want  string
+	}{
+		{"2.345", RoundHalfUp, "2.35"},
+		{"-2.345", RoundHalfUp, "-2.35"},
+		{"2.345", RoundHalfEven, "2.34"},
+		{"2.355", RoundHalfEven, "2.36"},
+		{"2.349", RoundDown, "2.34"},
+		{"-2.349", RoundDown, "-2.34"},
+	}
+	for _, tt := range tests {
+		d, err := ParseDecimal(tt.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := d.Round(2, tt.mode).String(); got != tt.want {
+			t.Fatalf("Round(%s, %d) = %s, want %s", tt.input, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestApplyDiscount(t *testing.T) {
+	engine := NewEngine(nil, WithDecimal(DefaultDecimalConfig))
+	amount, err := engine.ApplyDiscount(json.Number("199.99"), 0.95)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amount.String() != "189.99" {
+		t.Fatalf("discount = %s, want 189.99", amount)
+	}
+	even := NewEngine(nil, WithDecimal(DecimalConfig{Scale: 0, Rounding: RoundHalfEven}))
+	amount, err = even.ApplyDiscount(5, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amount.String() != "2" {
+		t.Fatalf("discount = %s, want 2", amount)
+	}
+}
+
+func TestDecimalModeAppliesToAllEvaluationPaths(t *testing.T) {
+	// 299.9999999999999999 在 float64 下等于 300，十进制模式下小于 300
+	condition := &Condition{Operator: "gte", Field: "cart.total_amount", Value: 300}
+	data := map[string]interface{}{"cart": map[string]interface{}{"total_amount": json.Number("299.9999999999999999")}}
+	rules := []Rule{{RuleID: "R", Status: RuleStatusActive, Condition: condition}}
+
+	if ok, err := EvaluateCondition(condition, NewFact(data)); err != nil || !ok {
+		t.Fatalf("float interpreted = %v, %v; want true", ok, err)
+	}
+	if ok, err := EvaluateCondition(condition, NewFact(data), WithDecimal(DefaultDecimalConfig)); err != nil || ok {
+		t.Fatalf("decimal interpreted = %v, %v; want false", ok, err)
+	}
+	results, err := NewEngine(rules, WithDecimal(DefaultDecimalConfig)).Evaluate(context.Background(), NewFact(data))
+	if err != nil || len(results) != 0 {
+		t.Fatalf("decimal engine = %v, %v; want no hits", results, err)
+	}
+	results, err = NewReteEngine(rules, WithDecimal(DefaultDecimalConfig)).Evaluate(NewFact(data))
+	if err != nil || len(results) != 0 {
+		t.Fatalf("decimal rete = %v, %v; want no hits", results, err)
+	}
+}
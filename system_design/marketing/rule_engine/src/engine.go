@@ -1,27 +1,43 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Engine 管理规则编译与执行
 type Engine struct {
 	// 已编译规则集合，按优先级降序排列
 	rules []compiledRule
+	// 引擎配置，决定条件编译与执行语义
+	options engineOptions
+	// 激活规则引用的事实路径，用于评估前预取
+	deps     []factPath
+	compiler conditionCompiler
+	// 等值叶子上的判别索引，没有可索引的规则时为空
+	index *ruleIndex
 }
 
 type compiledRule struct {
 	// 规则元数据
-	meta      Rule
+	meta Rule
 	// 条件执行器：输入事实，输出是否命中
 	evaluator func(*Fact) (bool, error)
+	// 在 Engine.rules 中的下标，用于合并分组结果时按规则顺序排列
+	index int
+	// 所属索引字段的序号加一，0 表示未建索引
+	indexField int
 }
 
-func NewEngine(rules []Rule) *Engine {
+func NewEngine(rules []Rule, opts ...EngineOption) *Engine {
+	options := newEngineOptions(opts)
+	compiler := newConditionCompiler(options)
 	// 复制规则，避免外部修改影响引擎内部状态
 	copied := make([]Rule, len(rules))
 	copy(copied, rules)
@@ -32,21 +48,81 @@ func NewEngine(rules []Rule) *Engine {
 	compiled := make([]compiledRule, 0, len(copied))
 	for _, rule := range copied {
 		// 预编译条件表达式为可执行函数
-		eval, err := CompileCondition(rule.Condition)
+		eval, err := compiler.compile(rule.Condition)
 		if err != nil {
 			// 编译失败的规则直接跳过
 			continue
 		}
-		compiled = append(compiled, compiledRule{meta: rule, evaluator: eval})
+		compiled = append(compiled, compiledRule{meta: rule, evaluator: eval, index: len(compiled)})
 	}
-	return &Engine{rules: compiled}
+	return newCompiledEngine(compiled, options, compiler)
 }
 
-// Evaluate 顺序执行所有规则并返回命中结果
-func (e *Engine) Evaluate(fact *Fact) ([]Result, error) {
+func newCompiledEngine(rules []compiledRule, options engineOptions, compiler conditionCompiler) *Engine {
+	// 基于已编译的规则构建引擎，rules 需按优先级降序排列；重新编号下标，执行器在多个引擎间共享
+	compiled := make([]compiledRule, len(rules))
+	metas := make([]Rule, len(rules))
+	for i, rule := range rules {
+		rule.index = i
+		rule.indexField = 0
+		compiled[i] = rule
+		metas[i] = rule.meta
+	}
+	engine := &Engine{rules: compiled, options: options, deps: ruleDependencies(metas), compiler: compiler}
+	if !options.noRuleIndex {
+		engine.index = buildRuleIndex(compiled, compiler)
+	}
+	return engine
+}
+
+// Rules 返回引擎中成功编译的规则，按执行顺序排列
+func (e *Engine) Rules() []Rule {
+	rules := make([]Rule, len(e.rules))
+	for i, rule := range e.rules {
+		rules[i] = rule.meta
+	}
+	return rules
+}
+
+// Dependencies 返回激活规则引用的全部事实路径
+func (e *Engine) Dependencies() []string {
+	paths := make([]string, len(e.deps))
+	for i, path := range e.deps {
+		paths[i] = path.raw
+	}
+	return paths
+}
+
+func (e *Engine) prefetch(fact *Fact) *Fact {
+	// 开启预取时，在执行规则前并发触发全部依赖路径上的 loader，返回本次评估使用的视图
+	if e.options.prefetch {
+		return fact.prefetch(e.deps, e.options.prefetchParallelism)
+	}
+	return fact
+}
+
+// ApplyDiscount 按引擎的数值模式计算折后金额，十进制模式下按配置精度舍入
+func (e *Engine) ApplyDiscount(amount, rate interface{}) (Decimal, error) {
+	return e.options.comparator().multiply(amount, rate)
+}
+
+// Evaluate 顺序执行所有规则并返回命中结果：每条规则执行前检查 ctx，触发的 loader 同样受 ctx 的取消与截止时间约束。
+// 引擎设置了非 ErrorAbort 的 ErrorPolicy 时，出错的规则被跳过，需要错误明细时使用 EvaluateWith
+func (e *Engine) Evaluate(ctx context.Context, fact *Fact) ([]Result, error) {
+	fact = fact.WithContext(ctx)
+	fact = e.prefetch(fact)
 	return e.evaluateRules(e.rules, fact)
 }
 
+// EvaluateWithTrace 执行所有规则，并返回逐规则、逐节点的评估轨迹以及类型转换、loader 调用记录
+func (e *Engine) EvaluateWithTrace(fact *Fact) ([]Result, *EvaluationTrace, error) {
+	trace := &EvaluationTrace{}
+	fact = fact.withTrace(trace)
+	fact = e.prefetch(fact)
+	results, err := e.evaluateRules(e.rules, fact)
+	return results, trace, err
+}
+
 // EvaluateParallel 按分组并行评估规则，组内使用独立 Fact 副本
 func (e *Engine) EvaluateParallel(fact *Fact, groupKey func(Rule) string) ([]Result, error) {
 	if groupKey == nil {
@@ -57,6 +133,21 @@ func (e *Engine) EvaluateParallel(fact *Fact, groupKey func(Rule) string) ([]Res
 			return rule.Type
 		}
 	}
+	// 预取在分组前完成，各组副本直接复用已加载的数据
+	fact = e.prefetch(fact)
+	run, err := e.evaluateGroups(fact, groupKey, e.options.mode)
+	if err != nil {
+		return nil, err
+	}
+	if run.stopped != nil {
+		return nil, run.stopped
+	}
+	return run.results, nil
+}
+
+func (e *Engine) evaluateGroups(fact *Fact, groupKey func(Rule) string, mode ExecutionMode) (ruleRun, error) {
+	// 各组在共享数据树的副本上并发执行，汇总命中结果与执行进度；
+	// 限定命中条数时每组最多命中 mode 条，汇总后按规则顺序取前 mode 条
 	groups := map[string][]compiledRule{}
 	for _, rule := range e.rules {
 		key := groupKey(rule.meta)
@@ -65,63 +156,261 @@ func (e *Engine) EvaluateParallel(fact *Fact, groupKey func(Rule) string) ([]Res
 	var (
 		wg       sync.WaitGroup
 		mu       sync.Mutex
-		results  []Result
+		merged   ruleRun
 		firstErr error
 	)
+	// 各分组共享数据树与本次评估的加载组，同一 loader 在分组之间只执行一次
+	calls := newEvaluationLoadGroup()
 	for _, rules := range groups {
 		groupRules := rules
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			groupFact := fact.Clone()
-			groupResults, err := e.evaluateRules(groupRules, groupFact)
+			groupFact := fact.share(calls)
+			if fact.trace != nil {
+				groupFact = groupFact.withTrace(fact.trace)
+			}
+			run, err := e.runRules(groupRules, groupFact, mode)
 			mu.Lock()
 			defer mu.Unlock()
 			if err != nil && firstErr == nil {
 				firstErr = err
 				return
 			}
-			results = append(results, groupResults...)
+			merged.results = append(merged.results, run.results...)
+			merged.order = append(merged.order, run.order...)
+			merged.evaluated += run.evaluated
+			merged.remaining += run.remaining
+			merged.errors = append(merged.errors, run.errors...)
+			if run.stopped != nil && merged.stopped == nil {
+				merged.stopped = run.stopped
+			}
 		}()
 	}
 	wg.Wait()
 	if firstErr != nil {
-		return nil, firstErr
+		return ruleRun{}, firstErr
 	}
-	return results, nil
+	sort.SliceStable(merged.errors, func(i, j int) bool {
+		return merged.errors[i].index < merged.errors[j].index
+	})
+	if mode != AllHits {
+		merged.truncate(int(mode))
+	}
+	return merged, nil
+}
+
+// ruleRun 为一组规则的执行进度；stopped 非空表示评估上下文结束，剩余规则未执行
+type ruleRun struct {
+	results   []Result
+	evaluated int // 已执行的激活规则数
+	remaining int // 因上下文结束未执行的激活规则数
+	stopped   error
+	// 按 ErrorPolicy 吸收的规则错误
+	errors []RuleFailure
+	// 限定命中条数时记录 results 对应规则的下标，用于合并分组结果
+	order []int
+}
+
+func (r *ruleRun) truncate(limit int) {
+	// 按规则顺序排列命中结果并保留前 limit 条
+	sort.Sort(runOrder{r})
+	if len(r.results) > limit {
+		r.results = r.results[:limit]
+		r.order = r.order[:limit]
+	}
+}
+
+type runOrder struct{ run *ruleRun }
+
+func (o runOrder) Len() int           { return len(o.run.order) }
+func (o runOrder) Less(i, j int) bool { return o.run.order[i] < o.run.order[j] }
+func (o runOrder) Swap(i, j int) {
+	o.run.order[i], o.run.order[j] = o.run.order[j], o.run.order[i]
+	o.run.results[i], o.run.results[j] = o.run.results[j], o.run.results[i]
 }
 
 func (e *Engine) evaluateRules(rules []compiledRule, fact *Fact) ([]Result, error) {
-	// 逐条执行规则并汇总命中结果
-	var results []Result
+	run, err := e.runRules(rules, fact, e.options.mode)
+	if err != nil {
+		return nil, err
+	}
+	if run.stopped != nil {
+		return nil, run.stopped
+	}
+	return run.results, nil
+}
+
+func (e *Engine) runRules(rules []compiledRule, fact *Fact, mode ExecutionMode) (ruleRun, error) {
+	return e.runRulesWith(rules, fact, mode, nil)
+}
+
+func (e *Engine) runRulesWith(rules []compiledRule, fact *Fact, mode ExecutionMode, scratch *ruleScratch) (ruleRun, error) {
+	// 逐条执行规则并汇总命中结果；每条规则执行前检查 Fact 绑定的上下文，
+	// loader 因上下文结束而失败时同样停止，已命中的结果保留在 ruleRun 中。
+	// 命中条数达到 mode 后不再执行剩余规则，也不会触发它们的 loader。
+	// scratch 非空时复用其中的缓冲，用于批量评估
+	var run ruleRun
+	ctx := fact.Context()
+	done := ctx.Done()
+	var probe *indexProbe
+	if e.index != nil {
+		p := newIndexProbe(e.index, fact)
+		probe = &p
+	}
 	// 互斥组命中记录：同一组只能命中一次
-	mutexHit := map[string]bool{}
-	for _, rule := range rules {
+	var mutexHit map[string]bool
+	if scratch != nil {
+		mutexHit = scratch.reset()
+		run.results = scratch.results()
+	} else {
+		mutexHit = map[string]bool{}
+	}
+	for i := range rules {
+		rule := &rules[i]
 		// 非激活规则直接跳过
-		if rule.meta.Status != "" && strings.ToLower(rule.meta.Status) != "active" {
+		if !rule.active() {
+			fact.traceSkip(rule.meta, RuleInactive)
 			continue
 		}
 		// 互斥组已命中则跳过
 		if rule.meta.MutexGroup != "" && mutexHit[rule.meta.MutexGroup] {
+			fact.traceSkip(rule.meta, RuleSkippedMutexGroup)
+			continue
+		}
+		if done != nil && ctx.Err() != nil {
+			run.stop(ctx.Err(), rules[i:], fact)
+			return run, nil
+		}
+		if probe != nil && rule.indexField > 0 && !probe.candidate(rule.indexField-1, rule.index) {
+			// 索引叶子不成立，规则必然不命中
+			fact.traceSkip(rule.meta, RuleSkippedIndex)
+			run.evaluated++
 			continue
 		}
-		matched, err := rule.evaluator(fact)
+		var (
+			matched bool
+			err     error
+		)
+		if fact.trace != nil {
+			matched, err = fact.evaluateTraced(rule.meta, rule.evaluator)
+		} else {
+			matched, err = rule.evaluator(fact)
+		}
 		if err != nil {
-			return nil, err
+			if done != nil && ctx.Err() != nil && isContextError(err) {
+				run.stop(err, rules[i:], fact)
+				return run, nil
+			}
+			if policy := e.options.errorPolicy; policy != ErrorAbort {
+				// 隔离单条规则的错误，其余规则继续执行
+				run.errors = append(run.errors, RuleFailure{RuleID: rule.meta.RuleID, Err: err, index: rule.index})
+				if policy == ErrorAsFalse {
+					run.evaluated++
+				}
+				continue
+			}
+			return run, err
 		}
+		run.evaluated++
 		if matched {
 			// 记录命中结果
-			results = append(results, Result{RuleID: rule.meta.RuleID, Actions: rule.meta.Actions})
+			run.results = append(run.results, Result{RuleID: rule.meta.RuleID, Actions: rule.meta.Actions})
 			if rule.meta.MutexGroup != "" {
 				// 标记互斥组命中
 				mutexHit[rule.meta.MutexGroup] = true
 			}
+			if mode != AllHits {
+				run.order = append(run.order, rule.index)
+				if len(run.results) >= int(mode) {
+					fact.traceRest(rules[i+1:], RuleSkippedHitLimit)
+					return run, nil
+				}
+			}
 		}
 	}
-	return results, nil
+	return run, nil
 }
 
-func EvaluateCondition(condition *Condition, fact *Fact) (bool, error) {
+func (r *ruleRun) stop(err error, rest []compiledRule, fact *Fact) {
+	// 上下文结束：rest[0] 为正在执行的规则，其余激活规则均未执行
+	r.stopped = err
+	r.remaining = countActive(rest)
+	fact.traceRest(rest[1:], RuleNotEvaluated)
+}
+
+func (f *Fact) evaluateTraced(rule Rule, evaluator func(*Fact) (bool, error)) (bool, error) {
+	// 轨迹模式下执行单条规则，条件树由编译节点上的轨迹钩子记录，这里记录结果、loader 调用与耗时
+	ruleTrace := f.trace.addRule(rule, RuleConditionFalse)
+	f.traceRule = ruleTrace
+	defer func() { f.traceRule = nil }()
+	start := time.Now()
+	matched, err := evaluator(f)
+	f.trace.mu.Lock()
+	defer f.trace.mu.Unlock()
+	ruleTrace.Duration = time.Since(start)
+	if ruleTrace.Condition == nil {
+		// 条件在编译期折叠为常量，没有执行任何节点
+		ruleTrace.Condition = &NodeTrace{Outcome: NodeFalse}
+		if matched {
+			ruleTrace.Condition.Outcome = NodeTrue
+		}
+	}
+	switch {
+	case err != nil:
+		ruleTrace.Outcome = RuleError
+		if isContextError(err) && f.Context().Err() != nil {
+			ruleTrace.Outcome = RuleNotEvaluated
+		}
+		ruleTrace.Error = err.Error()
+	case matched:
+		ruleTrace.Outcome = RuleMatched
+	}
+	return matched, err
+}
+
+func (f *Fact) traceSkip(rule Rule, outcome string) {
+	if f.trace != nil {
+		f.trace.addRule(rule, outcome)
+	}
+}
+
+func (f *Fact) traceRest(rules []compiledRule, outcome string) {
+	// 记录因提前停止而未执行的激活规则
+	if f.trace == nil {
+		return
+	}
+	for _, rule := range rules {
+		if rule.active() {
+			f.trace.addRule(rule.meta, outcome)
+		}
+	}
+}
+
+func (r compiledRule) active() bool {
+	return r.meta.Status == "" || strings.ToLower(r.meta.Status) == "active"
+}
+
+func countActive(rules []compiledRule) int {
+	n := 0
+	for _, rule := range rules {
+		if rule.active() {
+			n++
+		}
+	}
+	return n
+}
+
+// EvaluateCondition 解释执行条件树；opts 中的数值模式等比较语义与 NewEngine 相同，未指定时使用默认语义
+func EvaluateCondition(condition *Condition, fact *Fact, opts ...EngineOption) (bool, error) {
+	compiler := defaultCompiler
+	if len(opts) > 0 {
+		compiler = newConditionCompiler(newEngineOptions(opts))
+	}
+	return compiler.evaluate(condition, fact)
+}
+
+func (c conditionCompiler) evaluate(condition *Condition, fact *Fact) (bool, error) {
 	// 递归解释执行条件树
 	if condition == nil {
 		return true, nil
@@ -133,7 +422,7 @@ func EvaluateCondition(condition *Condition, fact *Fact) (bool, error) {
 			return false, errors.New("AND requires children")
 		}
 		for i := range condition.Children {
-			ok, err := EvaluateCondition(&condition.Children[i], fact)
+			ok, err := c.evaluate(&condition.Children[i], fact)
 			if err != nil {
 				return false, err
 			}
@@ -147,7 +436,7 @@ func EvaluateCondition(condition *Condition, fact *Fact) (bool, error) {
 			return false, errors.New("OR requires children")
 		}
 		for i := range condition.Children {
-			ok, err := EvaluateCondition(&condition.Children[i], fact)
+			ok, err := c.evaluate(&condition.Children[i], fact)
 			if err != nil {
 				return false, err
 			}
@@ -160,139 +449,101 @@ func EvaluateCondition(condition *Condition, fact *Fact) (bool, error) {
 		if len(condition.Children) != 1 {
 			return false, errors.New("NOT requires exactly one child")
 		}
-		ok, err := EvaluateCondition(&condition.Children[0], fact)
+		ok, err := c.evaluate(&condition.Children[0], fact)
 		if err != nil {
 			return false, err
 		}
 		return !ok, nil
+	case ConditionAny, ConditionAll, ConditionNone:
+		if err := validateQuantifier(op, condition); err != nil {
+			return false, err
+		}
+		path, err := parseFactPath(condition.Field)
+		if err != nil {
+			return false, err
+		}
+		child := &condition.Children[0]
+		return evaluateQuantifier(op, path, fact, func(element *Fact) (bool, error) {
+			return c.evaluate(child, element)
+		})
 	default:
 		// 叶子节点条件
-		return evaluateLeaf(condition, fact)
+		return c.evaluateLeaf(condition, fact)
 	}
 }
 
 func CompileCondition(condition *Condition) (func(*Fact) (bool, error), error) {
 	// 将条件树编译为可执行函数，减少运行期开销
-	if condition == nil {
-		return func(*Fact) (bool, error) { return true, nil }, nil
+	return defaultCompiler.compile(condition)
+}
+
+func validateQuantifier(op string, condition *Condition) error {
+	// 量词节点需要列表路径与唯一的元素级子条件
+	if condition.Field == "" {
+		return fmt.Errorf("%s requires field", op)
 	}
-	op := strings.ToUpper(condition.Operator)
-	switch op {
-	case "AND":
-		if len(condition.Children) == 0 {
-			return nil, errors.New("AND requires children")
-		}
-		children := make([]func(*Fact) (bool, error), 0, len(condition.Children))
-		for i := range condition.Children {
-			fn, err := CompileCondition(&condition.Children[i])
-			if err != nil {
-				return nil, err
-			}
-			children = append(children, fn)
+	if len(condition.Children) != 1 {
+		return fmt.Errorf("%s requires exactly one child", op)
+	}
+	return nil
+}
+
+func evaluateQuantifier(op string, path factPath, fact *Fact, child func(*Fact) (bool, error)) (bool, error) {
+	// 逐个绑定列表元素并以元素为根执行子条件，ANY/ALL/NONE 均支持短路
+	list, ok, err := fact.getPath(path)
+	if err != nil {
+		return false, err
+	}
+	if !ok || list == nil {
+		// 缺失的列表按空列表处理
+		return op != ConditionAny, nil
+	}
+	rv := reflect.ValueOf(list)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return false, fmt.Errorf("%s requires list field: %s", op, path.raw)
+	}
+	for i := 0; i < rv.Len(); i++ {
+		matched, err := child(newElementFact(fact, rv.Index(i).Interface()))
+		if err != nil {
+			return false, err
 		}
-		return func(fact *Fact) (bool, error) {
-			for _, fn := range children {
-				ok, err := fn(fact)
-				if err != nil {
-					return false, err
-				}
-				if !ok {
-					return false, nil
-				}
+		switch op {
+		case ConditionAny:
+			if matched {
+				return true, nil
 			}
-			return true, nil
-		}, nil
-	case "OR":
-		if len(condition.Children) == 0 {
-			return nil, errors.New("OR requires children")
-		}
-		children := make([]func(*Fact) (bool, error), 0, len(condition.Children))
-		for i := range condition.Children {
-			fn, err := CompileCondition(&condition.Children[i])
-			if err != nil {
-				return nil, err
+		case ConditionAll:
+			if !matched {
+				return false, nil
 			}
-			children = append(children, fn)
-		}
-		return func(fact *Fact) (bool, error) {
-			for _, fn := range children {
-				ok, err := fn(fact)
-				if err != nil {
-					return false, err
-				}
-				if ok {
-					return true, nil
-				}
+		case ConditionNone:
+			if matched {
+				return false, nil
 			}
-			return false, nil
-		}, nil
-	case "NOT":
-		if len(condition.Children) != 1 {
-			return nil, errors.New("NOT requires exactly one child")
-		}
-		child, err := CompileCondition(&condition.Children[0])
-		if err != nil {
-			return nil, err
 		}
-		return func(fact *Fact) (bool, error) {
-			ok, err := child(fact)
-			if err != nil {
-				return false, err
-			}
-			return !ok, nil
-		}, nil
-	default:
-		// 叶子节点条件编译
-		return compileLeaf(condition)
 	}
+	// 遍历结束未短路：ANY 不成立，ALL/NONE 对空列表视为成立
+	return op != ConditionAny, nil
 }
 
-func compileLeaf(condition *Condition) (func(*Fact) (bool, error), error) {
-	// 编译单条比较条件为函数
-	if condition.Field == "" {
-		return nil, errors.New("leaf condition requires field")
+func newElementFact(parent *Fact, element interface{}) *Fact {
+	// 对象元素直接作为子条件的根，其他元素通过 ElementSelfField 引用
+	var fact *Fact
+	if m, ok := element.(map[string]interface{}); ok {
+		fact = NewFact(m)
+	} else if isStructLike(element) {
+		fact = NewStructFact(element)
+	} else {
+		fact = NewFact(map[string]interface{}{ElementSelfField: element})
 	}
-	field := condition.Field
-	operator := strings.ToLower(condition.Operator)
-	value := condition.Value
-	return func(fact *Fact) (bool, error) {
-		left, ok, err := getByPath(fact, field)
-		if err != nil {
-			return false, err
-		}
-		if !ok {
-			return false, nil
-		}
-		right, err := resolveValue(value, fact)
-		if err != nil {
-			return false, err
-		}
-		switch operator {
-		case "eq":
-			return isEqual(left, right), nil
-		case "ne":
-			return !isEqual(left, right), nil
-		case "gt":
-			return compareNumber(left, right, func(a, b float64) bool { return a > b })
-		case "gte":
-			return compareNumber(left, right, func(a, b float64) bool { return a >= b })
-		case "lt":
-			return compareNumber(left, right, func(a, b float64) bool { return a < b })
-		case "lte":
-			return compareNumber(left, right, func(a, b float64) bool { return a <= b })
-		case "in":
-			return isIn(left, right)
-		case "contains":
-			return contains(left, right)
-		case "bitmask_all":
-			return bitmaskAll(left, right)
-		default:
-			return false, fmt.Errorf("unsupported operator: %s", operator)
-		}
-	}, nil
+	fact.trace = parent.trace
+	fact.traceRule = parent.traceRule
+	fact.traceNode = parent.traceNode
+	fact.ctx = parent.ctx
+	return fact
 }
 
-func evaluateLeaf(condition *Condition, fact *Fact) (bool, error) {
+func (c conditionCompiler) evaluateLeaf(condition *Condition, fact *Fact) (bool, error) {
 	// 解释执行单条比较条件
 	if condition.Field == "" {
 		return false, errors.New("leaf condition requires field")
@@ -308,40 +559,57 @@ func evaluateLeaf(condition *Condition, fact *Fact) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	switch strings.ToLower(condition.Operator) {
+	operator := strings.ToLower(condition.Operator)
+	if norm := c.normalizerFor(condition.Field, operator); norm != nil {
+		left, right = norm.value(left), norm.value(right)
+	}
+	matched, note, err := applyOperator(c.cmp, operator, left, right)
+	if note != "" {
+		fact.recordCoercion(condition.Field, operator, left, right, note)
+	}
+	return matched, err
+}
+
+func applyOperator(cmp comparator, operator string, left, right interface{}) (bool, string, error) {
+	// 按操作符比较左右值，operator 需已转为小写；第二个返回值为类型转换说明
+	switch operator {
 	case "eq":
-		return isEqual(left, right), nil
+		return cmp.isEqual(left, right)
 	case "ne":
-		return !isEqual(left, right), nil
-	case "gt":
-		return compareNumber(left, right, func(a, b float64) bool { return a > b })
-	case "gte":
-		return compareNumber(left, right, func(a, b float64) bool { return a >= b })
-	case "lt":
-		return compareNumber(left, right, func(a, b float64) bool { return a < b })
-	case "lte":
-		return compareNumber(left, right, func(a, b float64) bool { return a <= b })
+		matched, note, err := cmp.isEqual(left, right)
+		return !matched && err == nil, note, err
+	case "gt", "gte", "lt", "lte":
+		order, note, err := cmp.compareNumber(left, right)
+		if err != nil {
+			return false, note, err
+		}
+		switch operator {
+		case "gt":
+			return order > 0, note, nil
+		case "gte":
+			return order >= 0, note, nil
+		case "lt":
+			return order < 0, note, nil
+		default:
+			return order <= 0, note, nil
+		}
 	case "in":
-		return isIn(left, right)
+		return cmp.isIn(left, right)
 	case "contains":
-		return contains(left, right)
+		return cmp.contains(left, right)
 	case "bitmask_all":
-		return bitmaskAll(left, right)
+		return cmp.bitmaskAll(left, right)
 	default:
-		return false, fmt.Errorf("unsupported operator: %s", condition.Operator)
+		return false, "", fmt.Errorf("unsupported operator: %s", operator)
 	}
 }
 
 func resolveValue(value interface{}, fact *Fact) (interface{}, error) {
 	// 支持 {"var": "path"} 形式的动态取值
-	m, ok := value.(map[string]interface{})
+	ref, ok := varRef(value)
 	if !ok {
 		return value, nil
 	}
-	ref, ok := m["var"].(string)
-	if !ok || ref == "" {
-		return value, nil
-	}
 	v, ok, err := getByPath(fact, ref)
 	if err != nil {
 		return nil, err
@@ -352,6 +620,19 @@ func resolveValue(value interface{}, fact *Fact) (interface{}, error) {
 	return v, nil
 }
 
+func varRef(value interface{}) (string, bool) {
+	// 识别 {"var": "path"} 引用，返回被引用的路径
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	ref, ok := m["var"].(string)
+	if !ok || ref == "" {
+		return "", false
+	}
+	return ref, true
+}
+
 func getByPath(fact *Fact, path string) (interface{}, bool, error) {
 	// 通过路径读取事实中的字段
 	return fact.GetPath(path)
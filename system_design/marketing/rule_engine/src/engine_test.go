@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+)
+
+// evaluateAllPaths 分别用解释执行、编译执行与 Rete 网络评估同一条件，三者结果需一致
+func evaluateAllPaths(t *testing.T, condition *Condition, data map[string]interface{}) (bool, error) {
+	t.Helper()
+	interpreted, interpretedErr := EvaluateCondition(condition, NewFact(data))
+	compiled, err := CompileCondition(condition)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	ok, compiledErr := compiled(NewFact(data))
+	if ok != interpreted || (compiledErr == nil) != (interpretedErr == nil) {
+		t.Fatalf("compiled = %v, %v; interpreted = %v, %v", ok, compiledErr, interpreted, interpretedErr)
+	}
+	rule := Rule{RuleID: "R", Status: RuleStatusActive, Condition: condition}
+	results, reteErr := NewReteEngine([]Rule{rule}).Evaluate(NewFact(data))
+	if compiledErr == nil && (reteErr != nil || (len(results) == 1) != ok) {
+		t.Fatalf("rete = %v, %v; compiled = %v", results, reteErr, ok)
+	}
+	return ok, compiledErr
+}
+
+func TestQuantifiers(t *testing.T) {
+	items := []interface{}{
+		map[string]interface{}{"category": ItemCategoryFresh, "price": 60, "self_operated": true},
+		map[string]interface{}{"category": "数码", "price": 20, "self_operated": true},
+	}
+	freshOver50 := Condition{Operator: "AND", Children: []Condition{
+		{Operator: "eq", Field: "category", Value: ItemCategoryFresh},
+		{Operator: "gte", Field: "price", Value: 50},
+	}}
+	selfOperated := Condition{Operator: "eq", Field: "self_operated", Value: true}
+	tests := []struct {
+		name  string
+		op    string
+		child Condition
+		data  map[string]interface{}
+		want  bool
+	}{
+		{"any matches", ConditionAny, freshOver50, map[string]interface{}{"cart": map[string]interface{}{"items": items}}, true},
+		{"all matches", ConditionAll, selfOperated, map[string]interface{}{"cart": map[string]interface{}{"items": items}}, true},
+		{"all fails", ConditionAll, freshOver50, map[string]interface{}{"cart": map[string]interface{}{"items": items}}, false},
+		{"none fails", ConditionNone, freshOver50, map[string]interface{}{"cart": map[string]interface{}{"items": items}}, false},
+		{"empty any", ConditionAny, selfOperated, map[string]interface{}{"cart": map[string]interface{}{"items": []interface{}{}}}, false},
+		{"empty all", ConditionAll, selfOperated, map[string]interface{}{"cart": map[string]interface{}{"items": []interface{}{}}}, true},
+		{"empty none", ConditionNone, selfOperated, map[string]interface{}{"cart": map[string]interface{}{"items": []interface{}{}}}, true},
+		{"missing any", ConditionAny, selfOperated, map[string]interface{}{"cart": map[string]interface{}{}}, false},
+		{"missing all", ConditionAll, selfOperated, map[string]interface{}{"cart": map[string]interface{}{}}, true},
+		{"missing none", ConditionNone, selfOperated, map[string]interface{}{"cart": map[string]interface{}{}}, true},
+		{"null all", ConditionAll, selfOperated, map[string]interface{}{"cart": map[string]interface{}{"items": nil}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			condition := &Condition{Operator: tt.op, Field: "cart.items", Children: []Condition{tt.child}}
+			got, err := evaluateAllPaths(t, condition, tt.data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuantifierScalarElements(t *testing.T) {
+	condition := &Condition{Operator: ConditionAny, Field: "user.tags", Children: []Condition{
+		{Operator: "eq", Field: ElementSelfField, Value: UserTagHighValue},
+	}}
+	data := map[string]interface{}{"user": map[string]interface{}{"tags": []string{"new", UserTagHighValue}}}
+	if got, err := evaluateAllPaths(t, condition, data); err != nil || !got {
+		t.Fatalf("got %v, %v; want true", got, err)
+	}
+}
+
+func TestQuantifierErrors(t *testing.T) {
+	child := []Condition{{Operator: "eq", Field: "id", Value: 1}}
+	invalid := []*Condition{
+		{Operator: ConditionAny, Children: child},
+		{Operator: ConditionAll, Field: "cart.items"},
+	}
+	for _, condition := range invalid {
+		if _, err := CompileCondition(condition); err == nil {
+			t.Fatalf("expected compile error for %+v", condition)
+		}
+		if _, err := EvaluateCondition(condition, NewFact(nil)); err == nil {
+			t.Fatalf("expected evaluation error for %+v", condition)
+		}
+	}
+	notList := &Condition{Operator: ConditionAll, Field: "cart.items", Children: child}
+	data := map[string]interface{}{"cart": map[string]interface{}{"items": "x"}}
+	if _, err := evaluateAllPaths(t, notList, data); err == nil {
+		t.Fatal("expected error for non-list field")
+	}
+}
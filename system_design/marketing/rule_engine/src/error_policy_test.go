@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+var errProfileDown = errors.New("profile service down")
+
+func errorPolicyRules() []Rule {
+	// BAD_TYPE 对字符串做数值比较，BAD_LOADER 依赖失败的 loader，其余规则正常命中
+	rules := []Rule{
+		activeRule("FIRST", 5, leaf("eq", "scene", "home")),
+		activeRule("BAD_TYPE", 4, leaf("gt", "scene", 1)),
+		activeRule("BAD_LOADER", 3, leaf("eq", "profile.level", 3)),
+		activeRule("LAST", 2, leaf("ne", "scene", "cart")),
+	}
+	for i := range rules {
+		rules[i].Type = []string{"a", "a", "b", "b"}[i]
+	}
+	return rules
+}
+
+func errorPolicyFact() *Fact {
+	fact := homeFact()
+	fact.SetLoader("profile.level", func() (interface{}, error) {
+		return nil, errProfileDown
+	})
+	return fact
+}
+
+func TestErrorAbortDiscardsResults(t *testing.T) {
+	engine := NewEngine(errorPolicyRules())
+	results, err := engine.Evaluate(context.Background(), errorPolicyFact())
+	if err == nil || results != nil {
+		t.Fatalf("abort = %v, %v", results, err)
+	}
+	evaluation, err := engine.EvaluateWith(context.Background(), errorPolicyFact())
+	if err == nil || evaluation != nil {
+		t.Fatalf("abort evaluation = %+v, %v", evaluation, err)
+	}
+}
+
+func TestErrorSkipRule(t *testing.T) {
+	engine := NewEngine(errorPolicyRules(), WithErrorPolicy(ErrorSkipRule))
+	evaluation, err := engine.EvaluateWith(context.Background(), errorPolicyFact())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resultIDs(evaluation.Results); !reflect.DeepEqual(got, []string{"FIRST", "LAST"}) {
+		t.Fatalf("results = %v", got)
+	}
+	if got := failureIDs(evaluation.Errors); !reflect.DeepEqual(got, []string{"BAD_TYPE", "BAD_LOADER"}) {
+		t.Fatalf("errors = %v", got)
+	}
+	if evaluation.Evaluated != 2 || evaluation.Status != StatusCompleted {
+		t.Fatalf("evaluated = %d, status = %s", evaluation.Evaluated, evaluation.Status)
+	}
+	if !errors.Is(evaluation.Errors[1], errProfileDown) {
+		t.Fatalf("loader failure = %v", evaluation.Errors[1])
+	}
+	var failure RuleFailure
+	if !errors.As(error(evaluation.Errors[0]), &failure) || failure.RuleID != "BAD_TYPE" {
+		t.Fatalf("errors.As = %+v", failure)
+	}
+
+	results, err := engine.Evaluate(context.Background(), errorPolicyFact())
+	if err != nil || !reflect.DeepEqual(resultIDs(results), []string{"FIRST", "LAST"}) {
+		t.Fatalf("Evaluate = %v, %v", resultIDs(results), err)
+	}
+}
+
+func TestErrorAsFalseCountsRules(t *testing.T) {
+	engine := NewEngine(errorPolicyRules(), WithErrorPolicy(ErrorAsFalse))
+	evaluation, err := engine.EvaluateWith(context.Background(), errorPolicyFact())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evaluation.Evaluated != 4 || len(evaluation.Errors) != 2 || len(evaluation.Results) != 2 {
+		t.Fatalf("evaluation = %+v", evaluation)
+	}
+}
+
+func TestErrorPolicyParallelGroups(t *testing.T) {
+	// 分组并行时一个分组出错不影响其他分组，错误按规则顺序汇总
+	engine := NewEngine(errorPolicyRules(), WithErrorPolicy(ErrorSkipRule))
+	byType := func(rule Rule) string { return rule.Type }
+	for i := 0; i < 20; i++ {
+		evaluation, err := engine.EvaluateWith(context.Background(), errorPolicyFact(), WithGroups(byType))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 命中结果按分组完成顺序汇总，只比较集合
+		got := resultIDs(evaluation.Results)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, []string{"FIRST", "LAST"}) {
+			t.Fatalf("results = %v", got)
+		}
+		if got := failureIDs(evaluation.Errors); !reflect.DeepEqual(got, []string{"BAD_TYPE", "BAD_LOADER"}) {
+			t.Fatalf("errors = %v", got)
+		}
+		results, err := engine.EvaluateParallel(errorPolicyFact(), byType)
+		if err != nil || len(results) != 2 {
+			t.Fatalf("EvaluateParallel = %v, %v", resultIDs(results), err)
+		}
+	}
+
+	abort := NewEngine(errorPolicyRules())
+	if results, err := abort.EvaluateParallel(errorPolicyFact(), byType); err == nil || results != nil {
+		t.Fatalf("abort parallel = %v, %v", results, err)
+	}
+}
+
+func TestErrorPolicyBatch(t *testing.T) {
+	engine := NewEngine(errorPolicyRules(), WithErrorPolicy(ErrorSkipRule))
+	batch := engine.EvaluateBatch([]*Fact{errorPolicyFact(), NewFact(map[string]interface{}{"scene": 7})})
+	if batch[0].Err != nil || len(batch[0].Results) != 2 || len(batch[0].Errors) != 2 {
+		t.Fatalf("batch[0] = %+v", batch[0])
+	}
+	// 第二个事实的 scene 为数值：BAD_TYPE 不出错，FIRST 不命中；没有 loader 的 profile.level 视为不存在
+	if batch[1].Err != nil || !reflect.DeepEqual(resultIDs(batch[1].Results), []string{"BAD_TYPE", "LAST"}) || len(batch[1].Errors) != 0 {
+		t.Fatalf("batch[1] = %+v", batch[1])
+	}
+}
+
+func TestErrorPolicyTraceOutcome(t *testing.T) {
+	engine := NewEngine(errorPolicyRules(), WithErrorPolicy(ErrorSkipRule), WithTracing())
+	evaluation, err := engine.EvaluateWith(context.Background(), errorPolicyFact())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := findRuleTrace(evaluation.Trace, "BAD_TYPE")
+	if rule == nil || rule.Outcome != RuleError || rule.Error == "" || rule.Condition.Outcome != NodeError {
+		t.Fatalf("trace = %+v", rule)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// EvaluationStatus 为一次评估的完成状态
+type EvaluationStatus string
+
+const (
+	StatusCompleted        EvaluationStatus = "completed"
+	StatusDeadlineExceeded EvaluationStatus = "deadline_exceeded"
+	StatusCanceled         EvaluationStatus = "canceled"
+)
+
+// DeadlinePolicy 决定评估上下文在规则执行完之前结束时的处理方式
+type DeadlinePolicy int
+
+const (
+	DeadlineFail    DeadlinePolicy = iota // 返回上下文错误，丢弃已命中的结果（默认）
+	DeadlinePartial                       // 返回已命中的结果，Status 标记为 deadline_exceeded 或 canceled
+)
+
+// ErrorPolicy 决定单条规则执行出错（类型不匹配、loader 失败等）时的处理方式；上下文结束不属于规则错误
+type ErrorPolicy int
+
+const (
+	ErrorAbort    ErrorPolicy = iota // 中止评估并返回错误，丢弃全部结果（默认）
+	ErrorSkipRule                    // 跳过出错的规则，不计入 Evaluated
+	ErrorAsFalse                     // 出错的规则视为未命中，计入 Evaluated
+)
+
+// RuleFailure 为单条规则执行失败的记录
+type RuleFailure struct {
+	RuleID string
+	Err    error
+	// 规则在引擎中的顺序，用于并行评估后按优先级排列
+	index int
+}
+
+func (e RuleFailure) Error() string {
+	return "rule " + e.RuleID + ": " + e.Err.Error()
+}
+
+func (e RuleFailure) Unwrap() error {
+	return e.Err
+}
+
+// ExecutionMode 为评估需要的命中条数，达到后停止执行剩余规则；规则按优先级执行，
+// 因此前 N 条命中即优先级最高的 N 条
+type ExecutionMode int
+
+const (
+	AllHits  ExecutionMode = 0 // 执行全部规则（默认）
+	FirstHit ExecutionMode = 1 // 命中第一条规则后停止
+)
+
+// TopN 在命中 n 条规则后停止，n<=0 时等同于 AllHits
+func TopN(n int) ExecutionMode {
+	return ExecutionMode(n).normalized()
+}
+
+func (m ExecutionMode) normalized() ExecutionMode {
+	// 直接转换得到的负数模式没有意义，按 AllHits 处理
+	if m < 0 {
+		return AllHits
+	}
+	return m
+}
+
+// Evaluation 为 EvaluateWith 的评估结果
+type Evaluation struct {
+	Results   []Result
+	Status    EvaluationStatus
+	Evaluated int // 已执行的激活规则数
+	Remaining int // 因上下文结束未执行的激活规则数
+	// Errors 为执行出错的规则，按规则顺序排列，仅在引擎的 ErrorPolicy 不是 ErrorAbort 时出现
+	Errors []RuleFailure
+	// Trace 为逐节点的评估轨迹，仅在引擎开启 WithTracing 时记录
+	Trace *EvaluationTrace
+}
+
+// EvaluateOption 定制单次评估的行为
+type EvaluateOption func(*evaluateOptions)
+
+type evaluateOptions struct {
+	deadline DeadlinePolicy
+	// 非空时按分组并行评估
+	groupKey func(Rule) string
+	// 执行模式，默认取引擎的 WithExecutionMode
+	mode ExecutionMode
+}
+
+// WithDeadlinePolicy 设置上下文结束时的处理方式
+func WithDeadlinePolicy(policy DeadlinePolicy) EvaluateOption {
+	return func(o *evaluateOptions) {
+		o.deadline = policy
+	}
+}
+
+// WithGroups 按 groupKey 分组并行评估，各组共享同一上下文
+func WithGroups(groupKey func(Rule) string) EvaluateOption {
+	return func(o *evaluateOptions) {
+		o.groupKey = groupKey
+	}
+}
+
+// WithMode 为本次评估指定执行模式，覆盖引擎的 WithExecutionMode；mode<=0 时等同于 AllHits
+func WithMode(mode ExecutionMode) EvaluateOption {
+	return func(o *evaluateOptions) {
+		o.mode = mode.normalized()
+	}
+}
+
+// EvaluateWith 在 ctx 约束下评估规则：每条规则执行前检查取消与截止时间，loader 同样受 ctx 约束。
+// 上下文提前结束时，DeadlineFail 返回错误，DeadlinePartial 返回截至当时已命中的结果
+func (e *Engine) EvaluateWith(ctx context.Context, fact *Fact, opts ...EvaluateOption) (*Evaluation, error) {
+	options := evaluateOptions{mode: e.options.mode}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	fact = fact.WithContext(ctx)
+	var trace *EvaluationTrace
+	if e.options.tracing {
+		trace = &EvaluationTrace{}
+		fact = fact.withTrace(trace)
+	}
+	fact = e.prefetch(fact)
+	var (
+		run ruleRun
+		err error
+	)
+	if options.groupKey != nil {
+		run, err = e.evaluateGroups(fact, options.groupKey, options.mode)
+	} else {
+		run, err = e.runRules(e.rules, fact, options.mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	evaluation := &Evaluation{
+		Results:   run.results,
+		Status:    StatusCompleted,
+		Evaluated: run.evaluated,
+		Remaining: run.remaining,
+		Errors:    run.errors,
+		Trace:     trace,
+	}
+	if run.stopped == nil {
+		return evaluation, nil
+	}
+	if options.deadline == DeadlineFail {
+		return nil, run.stopped
+	}
+	evaluation.Status = StatusCanceled
+	if errors.Is(run.stopped, context.DeadlineExceeded) {
+		evaluation.Status = StatusDeadlineExceeded
+	}
+	return evaluation, nil
+}
+
+// EvaluateParallelContext 按分组并行评估，各组在规则之间检查 ctx，任一组因上下文结束停止时返回错误
+func (e *Engine) EvaluateParallelContext(ctx context.Context, fact *Fact, groupKey func(Rule) string) ([]Result, error) {
+	return e.EvaluateParallel(fact.WithContext(ctx), groupKey)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func deadlineRules() []Rule {
+	var rules []Rule
+	for i, field := range []string{"a", "b", "c"} {
+		rules = append(rules, activeRule(field, 3-i, leaf("eq", field, 1)))
+	}
+	return rules
+}
+
+func TestEvaluateWithCanceledBetweenRules(t *testing.T) {
+	newFact := func(cancel context.CancelFunc) *Fact {
+		// 读取第二条规则的字段时上下文结束，第三条规则不再执行
+		return NewStructFact(cancelingAccessor{cancel: cancel})
+	}
+	engine := NewEngine(deadlineRules())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := engine.EvaluateWith(ctx, newFact(cancel)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("fail policy err = %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	evaluation, err := engine.EvaluateWith(ctx, newFact(cancel), WithDeadlinePolicy(DeadlinePartial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evaluation.Status != StatusCanceled || evaluation.Evaluated != 2 || evaluation.Remaining != 1 {
+		t.Fatalf("evaluation = %+v", evaluation)
+	}
+	if got := resultIDs(evaluation.Results); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("results = %v", got)
+	}
+}
+
+// cancelingAccessor 读取字段 b 时取消评估上下文
+type cancelingAccessor struct {
+	cancel context.CancelFunc
+}
+
+func (a cancelingAccessor) GetField(name string) (interface{}, bool) {
+	if name == "b" {
+		a.cancel()
+	}
+	return 1, true
+}
+
+func TestEvaluateWithDeadlineExceeded(t *testing.T) {
+	fact := NewFact(map[string]interface{}{"a": 1})
+	fact.SetContextLoader("b", func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	evaluation, err := NewEngine(deadlineRules()).EvaluateWith(ctx, fact, WithDeadlinePolicy(DeadlinePartial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evaluation.Status != StatusDeadlineExceeded || len(evaluation.Results) != 1 || evaluation.Remaining != 2 {
+		t.Fatalf("evaluation = %+v", evaluation)
+	}
+}
+
+func TestEvaluateWithCompleted(t *testing.T) {
+	fact := NewFact(map[string]interface{}{"a": 1, "b": 2, "c": 1})
+	evaluation, err := NewEngine(deadlineRules()).EvaluateWith(context.Background(), fact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evaluation.Status != StatusCompleted || evaluation.Evaluated != 3 || evaluation.Remaining != 0 {
+		t.Fatalf("evaluation = %+v", evaluation)
+	}
+	if got := resultIDs(evaluation.Results); !reflect.DeepEqual(got, []string{"a", "c"}) {
+		t.Fatalf("results = %v", got)
+	}
+}
+
+func TestEvaluateParallelContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	groupByID := func(rule Rule) string { return rule.RuleID }
+	fact := NewFact(map[string]interface{}{"a": 1, "b": 1, "c": 1})
+	if _, err := NewEngine(deadlineRules()).EvaluateParallelContext(ctx, fact, groupByID); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v", err)
+	}
+	evaluation, err := NewEngine(deadlineRules()).EvaluateWith(ctx, fact, WithGroups(groupByID), WithDeadlinePolicy(DeadlinePartial))
+	if err != nil || evaluation.Status != StatusCanceled || evaluation.Remaining != 3 {
+		t.Fatalf("evaluation = %+v, %v", evaluation, err)
+	}
+}
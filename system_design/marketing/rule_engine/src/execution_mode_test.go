@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestExecutionModes(t *testing.T) {
+	byType := func(rule Rule) string { return rule.Type }
+	cases := []struct {
+		mode ExecutionMode
+		want []string
+	}{
+		{AllHits, []string{"P6", "P5", "P4", "P3", "P2", "P1"}},
+		{FirstHit, []string{"P6"}},
+		{TopN(3), []string{"P6", "P5", "P4"}},
+		{TopN(0), []string{"P6", "P5", "P4", "P3", "P2", "P1"}},
+	}
+	engine := NewEngine(homeRules(6))
+	for _, c := range cases {
+		evaluation, err := engine.EvaluateWith(context.Background(), homeFact(), WithMode(c.mode))
+		if err != nil || !reflect.DeepEqual(resultIDs(evaluation.Results), c.want) {
+			t.Fatalf("mode %d = %v, %v", c.mode, resultIDs(evaluation.Results), err)
+		}
+		// 分组并行的结果与顺序评估一致
+		evaluation, err = engine.EvaluateWith(context.Background(), homeFact(), WithMode(c.mode), WithGroups(byType))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := resultIDs(evaluation.Results)
+		if c.mode == AllHits {
+			// AllHits 下按分组完成顺序汇总，只比较集合
+			sort.Sort(sort.Reverse(sort.StringSlice(got)))
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("grouped mode %d = %v", c.mode, got)
+		}
+	}
+}
+
+func TestNegativeExecutionModeIsAllHits(t *testing.T) {
+	byType := func(rule Rule) string { return rule.Type }
+	engine := NewEngine(homeRules(6), WithExecutionMode(ExecutionMode(-1)))
+	results, err := engine.EvaluateParallel(homeFact(), byType)
+	if err != nil || len(results) != 6 {
+		t.Fatalf("EvaluateParallel = %v, %v", resultIDs(results), err)
+	}
+	results, err = engine.Evaluate(context.Background(), homeFact())
+	if err != nil || len(results) != 6 {
+		t.Fatalf("Evaluate = %v, %v", resultIDs(results), err)
+	}
+	evaluation, err := NewEngine(homeRules(6)).EvaluateWith(context.Background(), homeFact(), WithMode(ExecutionMode(-3)), WithGroups(byType))
+	if err != nil || len(evaluation.Results) != 6 {
+		t.Fatalf("EvaluateWith = %+v, %v", evaluation, err)
+	}
+	batch := engine.EvaluateBatch([]*Fact{homeFact()})
+	if batch[0].Err != nil || len(batch[0].Results) != 6 {
+		t.Fatalf("batch = %+v", batch[0])
+	}
+}
+
+func TestHitLimitTrace(t *testing.T) {
+	engine := NewEngine(homeRules(6), WithExecutionMode(FirstHit), WithTracing())
+	evaluation, err := engine.EvaluateWith(context.Background(), homeFact())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evaluation.Evaluated != 1 || len(evaluation.Trace.Rules) != 6 {
+		t.Fatalf("evaluation = %+v", evaluation)
+	}
+	for _, rule := range evaluation.Trace.Rules[1:] {
+		if rule.Outcome != RuleSkippedHitLimit {
+			t.Fatalf("rule %s outcome = %s", rule.RuleID, rule.Outcome)
+		}
+	}
+}
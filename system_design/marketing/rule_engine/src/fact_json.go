@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// NewFactFromJSON 从 JSON 对象创建 Fact。数值保留为 json.Number，
+// 超过 2^53 的整数（用户 ID、位掩码等）在比较与 bitmask_all 中不会丢失精度
+func NewFactFromJSON(data []byte) (*Fact, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var root map[string]interface{}
+	if err := decoder.Decode(&root); err != nil {
+		return nil, fmt.Errorf("decode fact: %w", err)
+	}
+	if root == nil {
+		return nil, errors.New("decode fact: top-level value must be an object")
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("decode fact: unexpected data after top-level object")
+	}
+	return NewFact(root), nil
+}
+
+// MarshalJSON 输出调用方提供的数据与已加载的值，未触发的 loader 不会被调用。
+// 结构体事实的顶层字段按 json 标签展开，data 中的同名字段优先
+func (f *Fact) MarshalJSON() ([]byte, error) {
+	st := f.state
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return json.Marshal(withSourceFields(f.source, st.data))
+}
+
+func withSourceFields(source interface{}, data map[string]interface{}) map[string]interface{} {
+	// 将结构体根节点按 json 标签展开后与 data 合并，data 中的同名字段优先；无法展开时只返回 data
+	if source == nil {
+		return data
+	}
+	encoded, err := json.Marshal(source)
+	if err != nil {
+		return data
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var root map[string]interface{}
+	if err := decoder.Decode(&root); err != nil || root == nil {
+		return data
+	}
+	for k, v := range data {
+		root[k] = v
+	}
+	return root
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewFactFromJSONPreservesLargeIntegers(t *testing.T) {
+	// 2^53 + 1 经 float64 解码会丢失最低位
+	fact, err := NewFactFromJSON([]byte(`{"user":{"id":9007199254740993,"level_mask":9007199254740995}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		condition *Condition
+		want      bool
+	}{
+		{&Condition{Operator: "eq", Field: "user.id", Value: int64(9007199254740993)}, true},
+		{&Condition{Operator: "eq", Field: "user.id", Value: int64(9007199254740992)}, false},
+		{&Condition{Operator: "bitmask_all", Field: "user.level_mask", Value: 1}, true},
+		{&Condition{Operator: "gt", Field: "user.id", Value: json.Number("9007199254740992")}, true},
+		{&Condition{Operator: "in", Field: "user.id", Value: []interface{}{int64(9007199254740992)}}, false},
+		{&Condition{Operator: "in", Field: "user.id", Value: []interface{}{1, int64(9007199254740993)}}, true},
+		{&Condition{Operator: "in", Field: "user.id", Value: []interface{}{json.Number("9007199254740992"), "x"}}, false},
+	} {
+		ok, err := EvaluateCondition(c.condition, fact)
+		if err != nil || ok != c.want {
+			t.Fatalf("%+v = %v, %v", c.condition, ok, err)
+		}
+		// 编译后的执行器与解释执行结果一致
+		results, err := NewEngine([]Rule{{RuleID: "R", Status: RuleStatusActive, Condition: c.condition}}).Evaluate(context.Background(), fact)
+		if err != nil || (len(results) == 1) != c.want {
+			t.Fatalf("compiled %+v = %v, %v", c.condition, resultIDs(results), err)
+		}
+	}
+}
+
+func TestNewFactFromJSONRejectsNonObjects(t *testing.T) {
+	for _, input := range []string{`[]`, `null`, `1`, `{"a":1} {"b":2}`, `{"a":`} {
+		if _, err := NewFactFromJSON([]byte(input)); err == nil {
+			t.Fatalf("%s: expected error", input)
+		}
+	}
+}
+
+func TestFactMarshalJSONIncludesLoadedValues(t *testing.T) {
+	input := `{"user":{"id":9007199254740993}}`
+	fact, err := NewFactFromJSON([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fact.SetLoader("user.level_mask", func() (interface{}, error) { return LevelMaskGold, nil })
+	fact.SetLoader("risk.score", func() (interface{}, error) {
+		t.Error("untriggered loader called")
+		return nil, nil
+	})
+	if _, err := NewEngine(levelRule()).Evaluate(context.Background(), fact); err != nil {
+		t.Fatal(err)
+	}
+	out, err := json.Marshal(fact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(out); got != `{"user":{"id":9007199254740993,"level_mask":2}}` {
+		t.Fatalf("marshal = %s", got)
+	}
+	// 输出可以重新解码为等价的 Fact
+	replayed, err := NewFactFromJSON(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := EvaluateCondition(levelRule()[0].Condition, replayed); !ok || err != nil {
+		t.Fatalf("replayed = %v, %v", ok, err)
+	}
+}
+
+func TestStructFactMarshalJSON(t *testing.T) {
+	type user struct {
+		City string `json:"city"`
+	}
+	type root struct {
+		User user `json:"user"`
+	}
+	fact := NewStructFact(root{User: user{City: UserCityBeijing}})
+	out, err := json.Marshal(fact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `"city":"北京"`) {
+		t.Fatalf("marshal = %s", out)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import "fmt"
+
+// 测试共用的事实、规则构造与结果提取
+
+// homeData 为多数用例共用的事实数据：scene 供等值规则与索引，vip 供布尔规则，amount 供数值规则
+func homeData() map[string]interface{} {
+	return map[string]interface{}{"scene": "home", "vip": true, "amount": 200}
+}
+
+func homeFact() *Fact {
+	return NewFact(homeData())
+}
+
+// homeRules 返回 n 条在 homeFact 上都会命中的规则，ID 为 P<n>..P1，优先级依次递减，Type 在 a、b 之间交替
+func homeRules(n int) []Rule {
+	rules := make([]Rule, 0, n)
+	for i := 0; i < n; i++ {
+		rule := activeRule(fmt.Sprintf("P%d", n-i), n-i, leaf("eq", "scene", "home"))
+		rule.Type = []string{"a", "b"}[i%2]
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func activeRule(id string, priority int, condition *Condition) Rule {
+	return Rule{RuleID: id, Priority: priority, Status: RuleStatusActive, Condition: condition}
+}
+
+func leaf(operator, field string, value interface{}) *Condition {
+	return &Condition{Operator: operator, Field: field, Value: value}
+}
+
+func and(children ...*Condition) *Condition {
+	return junction("AND", children)
+}
+
+func or(children ...*Condition) *Condition {
+	return junction("OR", children)
+}
+
+func junction(operator string, children []*Condition) *Condition {
+	condition := &Condition{Operator: operator, Children: make([]Condition, len(children))}
+	for i, child := range children {
+		condition.Children[i] = *child
+	}
+	return condition
+}
+
+func resultIDs(results []Result) []string {
+	ids := make([]string, 0, len(results))
+	for _, result := range results {
+		ids = append(ids, result.RuleID)
+	}
+	return ids
+}
+
+func failureIDs(failures []RuleFailure) []string {
+	ids := make([]string, 0, len(failures))
+	for _, failure := range failures {
+		ids = append(ids, failure.RuleID)
+	}
+	return ids
+}
+
+func findRuleTrace(trace *EvaluationTrace, id string) *RuleTrace {
+	for _, rule := range trace.Rules {
+		if rule.RuleID == id {
+			return rule
+		}
+	}
+	return nil
+}
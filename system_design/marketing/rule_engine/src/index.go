@@ -0,0 +1,185 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+)
+
+// ruleIndex 为等值叶子上的判别索引：字段 -> 取值 -> 候选规则。
+// 只为最先执行的叶子建索引（条件本身，或 AND 链的第一个子条件），该叶子为假时规则必然不命中，
+// 且在它之前没有其他子条件执行，跳过非候选规则不会改变结果、错误与 loader 调用。
+// 严格类型策略下字符串与布尔值只与同类型的相同值相等，因此只索引这两类常量
+type ruleIndex struct {
+	fields []indexedField
+}
+
+type indexedField struct {
+	path factPath
+	// 取值 -> 候选规则在 Engine.rules 中的下标，升序排列
+	values map[interface{}][]int
+}
+
+func buildRuleIndex(rules []compiledRule, compiler conditionCompiler) *ruleIndex {
+	// 为可索引的规则登记候选关系，并在 compiledRule.indexField 中记录所属字段
+	if compiler.cmp.coercion != CoercionStrict {
+		return nil
+	}
+	index := &ruleIndex{}
+	fieldSlots := map[string]int{}
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.active() {
+			continue
+		}
+		leaf := firstLeaf(rule.meta.Condition)
+		if leaf == nil {
+			continue
+		}
+		operator := strings.ToLower(leaf.Operator)
+		if compiler.normalizerFor(leaf.Field, operator) != nil {
+			continue
+		}
+		keys, ok := indexKeys(operator, leaf.Value)
+		if !ok {
+			continue
+		}
+		path, err := newFactPath(leaf.Field)
+		if err != nil {
+			continue
+		}
+		slot, ok := fieldSlots[path.raw]
+		if !ok {
+			slot = len(index.fields)
+			fieldSlots[path.raw] = slot
+			index.fields = append(index.fields, indexedField{path: path, values: map[interface{}][]int{}})
+		}
+		field := &index.fields[slot]
+		for _, key := range keys {
+			candidates := field.values[key]
+			// 同一条 in 规则的重复取值只登记一次
+			if n := len(candidates); n == 0 || candidates[n-1] != rule.index {
+				field.values[key] = append(candidates, rule.index)
+			}
+		}
+		rule.indexField = slot + 1
+	}
+	if len(index.fields) == 0 {
+		return nil
+	}
+	return index
+}
+
+func firstLeaf(condition *Condition) *Condition {
+	// 沿 AND 的第一个子条件下行，返回最先执行的叶子；遇到其他节点类型时返回 nil
+	for condition != nil {
+		switch strings.ToUpper(condition.Operator) {
+		case "AND":
+			if len(condition.Children) == 0 {
+				return nil
+			}
+			condition = &condition.Children[0]
+		case "OR", "NOT", ConditionAny, ConditionAll, ConditionNone:
+			return nil
+		default:
+			if condition.Field == "" {
+				return nil
+			}
+			return condition
+		}
+	}
+	return nil
+}
+
+func indexKeys(operator string, value interface{}) ([]interface{}, bool) {
+	// eq 的常量右值或 in 的常量列表，元素需全部为字符串或布尔值
+	if _, ok := varRef(value); ok {
+		return nil, false
+	}
+	switch operator {
+	case "eq":
+		if !indexable(value) {
+			return nil, false
+		}
+		return []interface{}{value}, true
+	case "in":
+		rv := reflect.ValueOf(value)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return nil, false
+		}
+		keys := make([]interface{}, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			element := rv.Index(i).Interface()
+			if !indexable(element) {
+				return nil, false
+			}
+			keys = append(keys, element)
+		}
+		return keys, true
+	default:
+		return nil, false
+	}
+}
+
+func indexable(value interface{}) bool {
+	switch value.(type) {
+	case string, bool:
+		return true
+	default:
+		return false
+	}
+}
+
+// indexProbe 为单次评估的索引查询状态：字段在第一条依赖它的规则执行时才取值，
+// 与顺序执行时触发 loader 的时机一致
+type indexProbe struct {
+	index *ruleIndex
+	fact  *Fact
+	// 索引字段不超过 4 个时使用内联数组，避免每次评估分配
+	small [4]probeState
+	large []probeState
+}
+
+type probeState struct {
+	resolved bool
+	// 取值失败时不做过滤，由规则自身返回错误
+	all        bool
+	candidates []int
+	// 规则按下标升序执行，游标单调前进
+	pos int
+}
+
+func newIndexProbe(index *ruleIndex, fact *Fact) indexProbe {
+	probe := indexProbe{index: index, fact: fact}
+	if len(index.fields) > len(probe.small) {
+		probe.large = make([]probeState, len(index.fields))
+	}
+	return probe
+}
+
+func (p *indexProbe) candidate(slot, rule int) bool {
+	// slot 为索引字段序号，rule 为规则在 Engine.rules 中的下标
+	var state *probeState
+	if p.large != nil {
+		state = &p.large[slot]
+	} else {
+		state = &p.small[slot]
+	}
+	if !state.resolved {
+		state.resolved = true
+		field := &p.index.fields[slot]
+		value, ok, err := p.fact.getPath(field.path)
+		switch {
+		case err != nil:
+			state.all = true
+		case ok && indexable(value):
+			state.candidates = field.values[value]
+		}
+	}
+	if state.all {
+		return true
+	}
+	for state.pos < len(state.candidates) && state.candidates[state.pos] < rule {
+		state.pos++
+	}
+	return state.pos < len(state.candidates) && state.candidates[state.pos] == rule
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func indexTestRules() []Rule {
+	// 覆盖可索引（eq、in、AND 链首）与不可索引（数值、OR、变量引用、非首位叶子）的规则
+	return []Rule{
+		activeRule("EQ_HOME", 10, leaf("eq", "scene", "home")),
+		activeRule("IN_CART", 9, leaf("in", "scene", []interface{}{"cart", "cart", "pay"})),
+		activeRule("AND_VIP", 8, and(leaf("eq", "vip", true), leaf("gt", "amount", 100))),
+		activeRule("NUMBER", 7, leaf("eq", "amount", 200)),
+		activeRule("OR", 6, or(leaf("eq", "scene", "home"), leaf("eq", "vip", false))),
+		activeRule("VAR_REF", 5, leaf("eq", "scene", map[string]interface{}{"var": "fallback"})),
+		activeRule("SECOND_LEAF", 4, and(leaf("gt", "amount", 10), leaf("eq", "scene", "pay"))),
+		{RuleID: "INACTIVE", Priority: 3, Status: "inactive", Condition: &Condition{Operator: "eq", Field: "scene", Value: "home"}},
+	}
+}
+
+func TestRuleIndexMatchesLinearScan(t *testing.T) {
+	indexed := NewEngine(indexTestRules())
+	linear := NewEngine(indexTestRules(), WithoutRuleIndex())
+	if indexed.index == nil || linear.index != nil {
+		t.Fatal("index not built as configured")
+	}
+	scenes := []interface{}{"home", "cart", "pay", "other", 1, nil}
+	vips := []interface{}{true, false, "true", nil}
+	amounts := []interface{}{200, 50, "oops", nil}
+	for _, scene := range scenes {
+		for _, vip := range vips {
+			for _, amount := range amounts {
+				data := map[string]interface{}{"fallback": "pay"}
+				for key, value := range map[string]interface{}{"scene": scene, "vip": vip, "amount": amount} {
+					if value != nil {
+						data[key] = value
+					}
+				}
+				want, wantErr := linear.EvaluateWith(context.Background(), NewFact(data))
+				got, gotErr := indexed.EvaluateWith(context.Background(), NewFact(data))
+				if fmt.Sprint(gotErr) != fmt.Sprint(wantErr) {
+					t.Fatalf("data %v: indexed err = %v, linear err = %v", data, gotErr, wantErr)
+				}
+				if wantErr != nil {
+					continue
+				}
+				if !reflect.DeepEqual(resultIDs(got.Results), resultIDs(want.Results)) || got.Evaluated != want.Evaluated {
+					t.Fatalf("data %v: indexed = %v (%d), linear = %v (%d)", data,
+						resultIDs(got.Results), got.Evaluated, resultIDs(want.Results), want.Evaluated)
+				}
+			}
+		}
+	}
+}
+
+func TestRuleIndexFields(t *testing.T) {
+	engine := NewEngine(indexTestRules())
+	indexedRules := map[string]bool{}
+	for _, rule := range engine.rules {
+		if rule.indexField > 0 {
+			indexedRules[rule.meta.RuleID] = true
+		}
+	}
+	want := map[string]bool{"EQ_HOME": true, "IN_CART": true, "AND_VIP": true}
+	if !reflect.DeepEqual(indexedRules, want) {
+		t.Fatalf("indexed rules = %v", indexedRules)
+	}
+	for _, field := range engine.index.fields {
+		if field.path.raw == "scene" {
+			if got := field.values["cart"]; len(got) != 1 {
+				t.Fatalf("duplicate in values registered %d times", len(got))
+			}
+		}
+	}
+}
+
+func TestRuleIndexDisabledByCoercionAndNormalization(t *testing.T) {
+	rules := []Rule{activeRule("R", 0, leaf("eq", "scene", "home"))}
+	if NewEngine(rules, WithCoercion(CoercionLenient)).index != nil {
+		t.Fatal("lenient coercion must not use the index")
+	}
+	normalized := NewEngine(rules, WithFieldNormalization("scene", StringNormalization{Trim: true, FoldCase: true}))
+	if normalized.index != nil {
+		t.Fatal("normalized field must not be indexed")
+	}
+	results, err := normalized.Evaluate(context.Background(), NewFact(map[string]interface{}{"scene": " HOME "}))
+	if err != nil || len(results) != 1 {
+		t.Fatalf("normalized = %v, %v", resultIDs(results), err)
+	}
+}
+
+func TestRuleIndexLoadsFieldLazily(t *testing.T) {
+	// 索引字段在第一条依赖它的规则执行时才取值：命中条数已满足时不触发其 loader
+	rules := []Rule{
+		activeRule("FIRST", 2, leaf("eq", "vip", true)),
+		activeRule("SCENE", 1, leaf("eq", "ctx.scene", "home")),
+	}
+	engine := NewEngine(rules)
+	newFact := func(loads *int) *Fact {
+		fact := NewFact(map[string]interface{}{"vip": true})
+		fact.SetLoader("ctx.scene", func() (interface{}, error) {
+			*loads++
+			return "home", nil
+		})
+		return fact
+	}
+	var loads int
+	evaluation, err := engine.EvaluateWith(context.Background(), newFact(&loads), WithMode(FirstHit))
+	if err != nil || len(evaluation.Results) != 1 || loads != 0 {
+		t.Fatalf("first hit = %+v, %v, loads = %d", evaluation, err, loads)
+	}
+	results, err := engine.Evaluate(context.Background(), newFact(&loads))
+	if err != nil || !reflect.DeepEqual(resultIDs(results), []string{"FIRST", "SCENE"}) || loads != 1 {
+		t.Fatalf("all hits = %v, %v, loads = %d", resultIDs(results), err, loads)
+	}
+}
+
+func TestRuleIndexLoaderErrorFallsThrough(t *testing.T) {
+	// 索引字段取值失败时不过滤，错误由规则自身返回
+	errDown := errors.New("scene service down")
+	rules := []Rule{activeRule("R", 0, leaf("eq", "ctx.scene", "home"))}
+	fact := NewFact(map[string]interface{}{})
+	fact.SetLoader("ctx.scene", func() (interface{}, error) { return nil, errDown })
+	if _, err := NewEngine(rules).Evaluate(context.Background(), fact); !errors.Is(err, errDown) {
+		t.Fatalf("err = %v", err)
+	}
+	evaluation, err := NewEngine(rules, WithErrorPolicy(ErrorSkipRule)).EvaluateWith(context.Background(), NewFact(map[string]interface{}{"ctx": map[string]interface{}{"scene": "cart"}}))
+	if err != nil || evaluation.Evaluated != 1 || len(evaluation.Results) != 0 {
+		t.Fatalf("skipped by index = %+v, %v", evaluation, err)
+	}
+}
+
+func TestSceneEnginePartitionsHaveOwnIndex(t *testing.T) {
+	// 分区重新编号规则下标，索引按分区重建
+	rules := indexTestRules()
+	for i := range rules {
+		rules[i].Type = []string{"a", "b"}[i%2]
+	}
+	scenes := NewSceneEngine(rules)
+	for _, ruleType := range scenes.Types() {
+		partition, _ := scenes.Type(ruleType)
+		linear := NewEngine(partition.Rules(), WithoutRuleIndex())
+		for _, scene := range []string{"home", "cart", "pay"} {
+			data := homeData()
+			data["scene"], data["fallback"] = scene, "pay"
+			got, err := partition.Evaluate(context.Background(), NewFact(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := linear.Evaluate(context.Background(), NewFact(data))
+			if !reflect.DeepEqual(resultIDs(got), resultIDs(want)) {
+				t.Fatalf("partition %s scene %s = %v, want %v", ruleType, scene, resultIDs(got), resultIDs(want))
+			}
+		}
+	}
+}
@@ -0,0 +1,509 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ContextLoader 为感知上下文的懒加载函数，应在 ctx 取消或超时后尽快返回。
+// 评估不会等待忽略 ctx 的 loader，但其 goroutine 会一直运行到 loader 自行返回
+type ContextLoader func(ctx context.Context) (interface{}, error)
+
+// LoaderOption 定制单个 loader 的超时与降级行为
+type LoaderOption func(*factLoader)
+
+// factLoader 为注册在路径上的 loader 及其配置
+type factLoader struct {
+	load ContextLoader
+	// 单次加载的超时时间，0 表示只受评估上下文约束
+	timeout time.Duration
+	// 加载失败或超时时使用的降级值
+	fallback    interface{}
+	hasFallback bool
+	// 批量 loader 的成员：key 为前缀下的子字段名，为空表示前缀节点本身
+	batch *batchGroup
+	key   string
+	// subtree 为真时，节点已部分存在也会加载并按 merge 策略合并
+	subtree bool
+	merge   MergePolicy
+}
+
+// MergePolicy 决定 subtree loader 的结果与调用方已提供的数据冲突时的取舍
+type MergePolicy int
+
+const (
+	MergeKeepProvided MergePolicy = iota // 调用方数据优先，加载结果只填补缺失的字段（默认）
+	MergePreferLoaded                    // 加载结果覆盖同名的调用方字段
+)
+
+// WithLoaderTimeout 限制单次加载耗时，超时后按降级值处理或返回 context.DeadlineExceeded
+func WithLoaderTimeout(timeout time.Duration) LoaderOption {
+	return func(l *factLoader) {
+		l.timeout = timeout
+	}
+}
+
+// WithLoaderFallback 设置降级值，loader 出错或超过自身超时时以该值代替；返回 ErrFactNotFound 时仍按缺失处理
+func WithLoaderFallback(value interface{}) LoaderOption {
+	return func(l *factLoader) {
+		l.fallback = value
+		l.hasFallback = true
+	}
+}
+
+// SetContextLoader 为指定路径注册感知上下文的懒加载函数
+func (f *Fact) SetContextLoader(path string, loader ContextLoader, opts ...LoaderOption) {
+	l := &factLoader{load: loader}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(l)
+		}
+	}
+	f.setLoader(path, l)
+}
+
+// SetSubtreeLoader 为指定路径注册整棵子树的 loader：节点缺失时直接写入；
+// 节点已部分提供但缺少访问的字段时也会触发，并把加载结果逐层合并到已有节点中
+func (f *Fact) SetSubtreeLoader(path string, loader ContextLoader, opts ...LoaderOption) {
+	l := &factLoader{load: loader, subtree: true}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(l)
+		}
+	}
+	f.setLoader(path, l)
+}
+
+// WithMergePolicy 设置 subtree loader 与调用方数据的合并策略
+func WithMergePolicy(policy MergePolicy) LoaderOption {
+	return func(l *factLoader) {
+		l.merge = policy
+	}
+}
+
+// WithContext 返回共享数据与 loader 的视图，视图上触发的加载受 ctx 的取消与截止时间约束
+func (f *Fact) WithContext(ctx context.Context) *Fact {
+	if ctx == nil {
+		panic("nil context")
+	}
+	view := *f
+	view.ctx = ctx
+	return &view
+}
+
+// Context 返回 Fact 绑定的上下文，未绑定时为 context.Background
+func (f *Fact) Context() context.Context {
+	if f.ctx != nil {
+		return f.ctx
+	}
+	return context.Background()
+}
+
+func (l *factLoader) run(ctx context.Context, path string) (interface{}, error) {
+	// 评估上下文已取消时不再发起加载；loader 自身超时或出错时才使用降级值
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+	loadCtx := ctx
+	if l.timeout > 0 {
+		var cancel context.CancelFunc
+		loadCtx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
+	val, err := callLoader(loadCtx, l.load)
+	if err == nil {
+		return val, nil
+	}
+	if l.hasFallback && ctx.Err() == nil && !errors.Is(err, ErrFactNotFound) {
+		return l.fallback, nil
+	}
+	return nil, fmt.Errorf("load %s: %w", path, err)
+}
+
+func callLoader(ctx context.Context, load ContextLoader) (interface{}, error) {
+	// 不可取消的上下文直接同步调用；否则在独立 goroutine 中执行，
+	// 忽略 ctx 的 loader 也不会让评估越过截止时间。Go 无法终止该 goroutine，
+	// ctx 结束后它继续运行到 loader 返回，结果写入带缓冲的 done 后随之回收。
+	// goroutine 中的 panic 无法被调用方恢复，转为该次加载的错误
+	if ctx.Done() == nil {
+		return load(ctx)
+	}
+	type outcome struct {
+		val interface{}
+		err error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- outcome{err: fmt.Errorf("loader panic: %v", r)}
+			}
+		}()
+		val, err := load(ctx)
+		done <- outcome{val, err}
+	}()
+	select {
+	case out := <-done:
+		return out.val, out.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// loadSite 描述一次加载的落点：loader 的结果写入 route 指向的 map 下 keys 指向的节点，keys 多于一个时中间节点需要创建。
+// 写回时总是按 route 从根节点重新定位，不持有下行时看到的节点
+type loadSite struct {
+	keyPath string
+	loader  *factLoader
+	route   []pathSegment
+	keys    []string
+}
+
+func (f *Fact) loadTarget(path factPath) (loadSite, bool) {
+	// 沿路径下行但不触发加载，在第一个缺失的节点处选择 loader；投影之后的节点无法加载
+	// 调用方持有读锁
+	var current interface{} = f.state.data
+	// containers[i] 为第 i 个片段所在的 map，非 map 节点为 nil
+	containers := make([]map[string]interface{}, 0, len(path.segments)+1)
+	for i, segment := range path.segments {
+		switch segment.kind {
+		case segmentWildcard:
+			return loadSite{}, false
+		case segmentIndex:
+			containers = append(containers, nil)
+			list, ok := listValue(current)
+			if !ok {
+				return loadSite{}, false
+			}
+			index := segment.index
+			if index < 0 {
+				index += list.Len()
+			}
+			if index < 0 || index >= list.Len() {
+				return loadSite{}, false
+			}
+			current = list.Index(index).Interface()
+			continue
+		}
+		m, ok := current.(map[string]interface{})
+		containers = append(containers, m)
+		if !ok {
+			val, ok := lookupField(current, segment.key)
+			if !ok {
+				return loadSite{}, false
+			}
+			current = val
+			continue
+		}
+		if val, ok := m[segment.key]; ok {
+			current = val
+			continue
+		}
+		if i == 0 && f.source != nil {
+			if val, ok := lookupField(f.source, segment.key); ok {
+				current = val
+				continue
+			}
+		}
+		return f.missingSite(path, i, containers)
+	}
+	return loadSite{}, false
+}
+
+func (f *Fact) missingSite(path factPath, i int, containers []map[string]interface{}) (loadSite, bool) {
+	// 候选顺序：缺失节点自身的 loader，其次最近祖先的 subtree loader，最后最近后代的 loader（自动创建中间节点）
+	segments := path.segments
+	if l, ok := f.pendingLoader(path.prefix(i)); ok {
+		return loadSite{keyPath: path.prefix(i), loader: l, route: segments[:i], keys: []string{segments[i].key}}, true
+	}
+	for k := i - 1; k >= 0; k-- {
+		// 祖先节点 k 已存在，只有其本身为 map 时才能合并
+		if segments[k].kind != segmentKey || containers[k] == nil || containers[k+1] == nil {
+			continue
+		}
+		if l, ok := f.pendingLoader(path.prefix(k)); ok && l.subtree {
+			return loadSite{keyPath: path.prefix(k), loader: l, route: segments[:k], keys: []string{segments[k].key}}, true
+		}
+	}
+	keys := []string{segments[i].key}
+	for j := i + 1; j < len(segments) && segments[j].kind == segmentKey; j++ {
+		keys = append(keys, segments[j].key)
+		if l, ok := f.pendingLoader(path.prefix(j)); ok {
+			return loadSite{keyPath: path.prefix(j), loader: l, route: segments[:i], keys: keys}, true
+		}
+	}
+	return loadSite{}, false
+}
+
+func (f *Fact) pendingLoader(keyPath string) (*factLoader, bool) {
+	// 已注册且尚未成功加载的 loader；预取失败的 loader 同样返回，由调用方决定是否报错
+	l, ok := f.state.loaders[keyPath]
+	if !ok || f.state.loaded[keyPath] {
+		return nil, false
+	}
+	return l, true
+}
+
+func (f *Fact) load(site loadSite) error {
+	// 在锁外加载单个落点：批量 loader 合并同一前缀下尚未加载的子字段，结果持写锁写回
+	st := f.state
+	if err := f.failed[site.keyPath]; err != nil {
+		return err
+	}
+	st.mu.RLock()
+	members := map[string]*factLoader{site.keyPath: site.loader}
+	if group := site.loader.batch; group != nil && site.loader.key != "" {
+		// 从根节点重新定位前缀节点，只合并此刻仍缺失的子字段
+		node, _, _ := f.walkPath(st.data, factPath{segments: site.route}, 0, true)
+		prefix, _ := node.(map[string]interface{})
+		for _, k := range group.keys {
+			path := group.paths[k]
+			if _, ok := prefix[k]; ok || st.loaded[path] {
+				continue
+			}
+			members[path] = st.loaders[path]
+		}
+	}
+	st.mu.RUnlock()
+	resolved, err := f.fetch(site.loader, members, func(resolved map[string]batchValue) {
+		st.mu.Lock()
+		defer st.mu.Unlock()
+		for path, member := range members {
+			value, ok := resolved[path]
+			if !ok {
+				continue
+			}
+			if path == site.keyPath {
+				f.storeValue(site, value)
+				continue
+			}
+			// 其余成员与被访问的子字段位于同一前缀节点下
+			f.storeValue(loadSite{keyPath: path, loader: member, route: site.route, keys: []string{member.key}}, value)
+		}
+	})
+	if rec := st.recorder; rec != nil {
+		for path, member := range members {
+			if value, ok := resolved[path]; ok || err != nil {
+				rec.record(path, member, value, err)
+			}
+		}
+	}
+	return err
+}
+
+func (f *Fact) fetch(key *factLoader, members map[string]*factLoader, store func(map[string]batchValue)) (map[string]batchValue, error) {
+	// 执行 members 对应的加载，返回 路径 -> 结果；确认不存在的事实按缺失返回。
+	// 成功时先由 store 写回，再结束单飞，之后到达的请求一定能看到写回的结果
+	ctx := f.Context()
+	if f.trace != nil {
+		start := time.Now()
+		resolved, err := f.doFetch(ctx, key, members, store)
+		f.recordLoad(members, time.Since(start), err)
+		return resolved, err
+	}
+	return f.doFetch(ctx, key, members, store)
+}
+
+func (f *Fact) doFetch(ctx context.Context, key *factLoader, members map[string]*factLoader, store func(map[string]batchValue)) (map[string]batchValue, error) {
+	return f.state.calls.do(ctx, key, members, store, func() (map[string]batchValue, error) {
+		if f.allLoaded(members) {
+			// 上一次单飞在本次访问看到缺失之后才写回并结束，不再重复加载
+			return map[string]batchValue{}, nil
+		}
+		if key.batch != nil {
+			resolved, err := key.batch.resolve(ctx, members)
+			if errors.Is(err, ErrFactNotFound) {
+				// 整个前缀不存在，所有成员按缺失处理
+				resolved, err = make(map[string]batchValue, len(members)), nil
+				for path := range members {
+					resolved[path] = batchValue{absent: true}
+				}
+			}
+			return resolved, err
+		}
+		resolved := make(map[string]batchValue, len(members))
+		for path, loader := range members {
+			val, err := loader.run(ctx, path)
+			if errors.Is(err, ErrFactNotFound) {
+				resolved[path] = batchValue{absent: true}
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			resolved[path] = batchValue{val: val}
+		}
+		return resolved, nil
+	})
+}
+
+func (f *Fact) storeValue(site loadSite, value batchValue) {
+	// 调用方持有写锁；标记 loader 已加载并写入结果，缺失的中间节点在写入时创建
+	if f.state.loaded[site.keyPath] {
+		// 并发访问的其他 goroutine 已写回同一结果
+		return
+	}
+	f.markLoaded(site.keyPath)
+	if site.loader.batch != nil && site.loader.key == "" {
+		// 批量 loader 的前缀节点按全部子字段加载
+		for _, path := range site.loader.batch.paths {
+			f.markLoaded(path)
+		}
+	}
+	if value.absent {
+		return
+	}
+	parent := f.writable(site.route, false)
+	if parent == nil {
+		return
+	}
+	last := len(site.keys) - 1
+	for _, key := range site.keys[:last] {
+		child, ok := parent[key].(map[string]interface{})
+		if ok {
+			child = copyNode(child).(map[string]interface{})
+		} else {
+			child = map[string]interface{}{}
+		}
+		parent[key] = child
+		parent = child
+	}
+	key := site.keys[last]
+	existing, exists := parent[key]
+	if !exists || !site.loader.subtree {
+		parent[key] = value.val
+		return
+	}
+	dst, dstMap := existing.(map[string]interface{})
+	src, srcMap := value.val.(map[string]interface{})
+	switch {
+	case dstMap && srcMap:
+		dst = copyNode(dst).(map[string]interface{})
+		parent[key] = dst
+		mergeMaps(dst, src, site.loader.merge)
+	case site.loader.merge == MergePreferLoaded:
+		parent[key] = value.val
+	}
+}
+
+func (f *Fact) allLoaded(members map[string]*factLoader) bool {
+	st := f.state
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	for path := range members {
+		if !st.loaded[path] {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *Fact) markLoaded(keyPath string) {
+	st := f.state
+	if st.loaded == nil {
+		st.loaded = map[string]bool{}
+	}
+	st.loaded[keyPath] = true
+}
+
+func mergeMaps(dst, src map[string]interface{}, policy MergePolicy) {
+	// 逐层合并：双方都是 map 时递归，否则按策略决定是否覆盖；dst 为新副本可原地修改，子节点先复制再合并
+	for k, v := range src {
+		existing, ok := dst[k]
+		if !ok {
+			dst[k] = v
+			continue
+		}
+		dstChild, dstMap := existing.(map[string]interface{})
+		srcChild, srcMap := v.(map[string]interface{})
+		if dstMap && srcMap {
+			dstChild = copyNode(dstChild).(map[string]interface{})
+			dst[k] = dstChild
+			mergeMaps(dstChild, srcChild, policy)
+			continue
+		}
+		if policy == MergePreferLoaded {
+			dst[k] = v
+		}
+	}
+}
+
+// loadGroup 对同一 loader 的加载做单飞：并发请求只执行一次，加载结束后即移除。
+// retain 为真时成功结果保留到该组被丢弃，一次并行评估的各分组副本共享这样的组，复用彼此的加载
+type loadGroup struct {
+	mu     sync.Mutex
+	calls  map[*factLoader]*loadCall
+	retain bool
+}
+
+type loadCall struct {
+	done     chan struct{}
+	resolved map[string]batchValue
+	err      error
+}
+
+func newLoadGroup() *loadGroup {
+	return &loadGroup{calls: map[*factLoader]*loadCall{}}
+}
+
+func (g *loadGroup) do(ctx context.Context, key *factLoader, members map[string]*factLoader, store func(map[string]batchValue), fn func() (map[string]batchValue, error)) (map[string]batchValue, error) {
+	// 成功结果由每个调用方各自交给 store 写回；发起方在结束单飞之前写回
+	for {
+		g.mu.Lock()
+		if call, ok := g.calls[key]; ok {
+			g.mu.Unlock()
+			select {
+			case <-call.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			if call.err != nil && ctx.Err() == nil && isContextError(call.err) {
+				// 发起方的上下文已结束而当前请求仍有效，重新发起加载
+				continue
+			}
+			if call.err == nil && store != nil {
+				store(call.resolved)
+			}
+			return call.resolved, call.err
+		}
+		call := &loadCall{done: make(chan struct{})}
+		g.calls[key] = call
+		g.mu.Unlock()
+
+		g.lead(call, key, members, store, fn)
+		return call.resolved, call.err
+	}
+}
+
+func (g *loadGroup) lead(call *loadCall, key *factLoader, members map[string]*factLoader, store func(map[string]batchValue), fn func() (map[string]batchValue, error)) {
+	// 发起方执行加载并结束单飞；loader 或写回 panic 时同样移除进行中的条目并唤醒等待者，
+	// panic 转为本次加载的错误，后续访问重新加载
+	defer func() {
+		if r := recover(); r != nil {
+			call.resolved, call.err = nil, fmt.Errorf("loader panic: %v", r)
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		if g.retain && call.err == nil {
+			// 批量加载一并得到的其他成员同样可复用；失败结果不保留，后续访问重新加载
+			for path, member := range members {
+				if _, ok := call.resolved[path]; ok {
+					g.calls[member] = call
+				}
+			}
+		}
+		g.mu.Unlock()
+		close(call.done)
+	}()
+	call.resolved, call.err = fn()
+	if call.err == nil && store != nil {
+		store(call.resolved)
+	}
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
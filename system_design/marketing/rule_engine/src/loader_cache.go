@@ -0,0 +1,198 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrFactNotFound 由 loader 返回，表示该事实确实不存在；路径按缺失处理，共享缓存会做负缓存
+var ErrFactNotFound = errors.New("fact not found")
+
+// LoaderCacheConfig 为跨请求 loader 缓存的配置
+type LoaderCacheConfig struct {
+	// TTL 为成功结果的有效期
+	TTL time.Duration
+	// NegativeTTL 为 ErrFactNotFound 结果的有效期，0 时沿用 TTL
+	NegativeTTL time.Duration
+	// MaxEntries 为缓存条目上限，超出后淘汰最久未使用的条目，0 表示不限制
+	MaxEntries int
+	// LoadTimeout 限制共享加载的耗时；加载不随发起请求的取消而中断，结果可供后续请求复用，0 表示不限制
+	LoadTimeout time.Duration
+}
+
+// LoaderCacheStats 为缓存命中统计
+type LoaderCacheStats struct {
+	Hits         uint64 // 命中有效结果
+	NegativeHits uint64 // 命中不存在结果
+	Misses       uint64 // 未命中并实际调用 loader
+	Coalesced    uint64 // 未命中但等待了进行中的同键加载
+	Evictions    uint64 // 因容量淘汰的条目
+	Size         int    // 当前条目数
+}
+
+// LoaderCache 在多个 Fact 之间共享 loader 结果，按 loader 名称 + 实体键缓存
+type LoaderCache struct {
+	mu       sync.Mutex
+	config   LoaderCacheConfig
+	entries  map[loaderCacheKey]*list.Element
+	lru      *list.List // 表头为最近使用
+	inflight map[loaderCacheKey]*loaderCall
+	stats    LoaderCacheStats
+}
+
+type loaderCacheKey struct {
+	name   string
+	entity string
+}
+
+type loaderCacheEntry struct {
+	key     loaderCacheKey
+	val     interface{}
+	err     error // 仅缓存 ErrFactNotFound
+	expires time.Time
+}
+
+// loaderCall 为进行中的加载，同键的并发请求等待同一结果，避免缓存击穿
+type loaderCall struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+// NewLoaderCache 创建共享 loader 缓存
+func NewLoaderCache(config LoaderCacheConfig) *LoaderCache {
+	if config.NegativeTTL == 0 {
+		config.NegativeTTL = config.TTL
+	}
+	return &LoaderCache{
+		config:   config,
+		entries:  map[loaderCacheKey]*list.Element{},
+		lru:      list.New(),
+		inflight: map[loaderCacheKey]*loaderCall{},
+	}
+}
+
+// Loader 返回带缓存的 loader，可直接用于 SetContextLoader
+func (c *LoaderCache) Loader(name, entity string, load ContextLoader) ContextLoader {
+	return func(ctx context.Context) (interface{}, error) {
+		return c.Get(ctx, name, entity, load)
+	}
+}
+
+// Get 读取缓存结果，未命中时调用 load；同键并发请求只调用一次 load
+func (c *LoaderCache) Get(ctx context.Context, name, entity string, load ContextLoader) (interface{}, error) {
+	key := loaderCacheKey{name: name, entity: entity}
+	c.mu.Lock()
+	if entry, ok := c.lookup(key); ok {
+		c.mu.Unlock()
+		return deepCopyValue(entry.val), entry.err
+	}
+	call, ok := c.inflight[key]
+	if ok {
+		c.stats.Coalesced++
+	} else {
+		c.stats.Misses++
+		call = &loaderCall{done: make(chan struct{})}
+		c.inflight[key] = call
+		go c.load(ctx, key, call, load)
+	}
+	c.mu.Unlock()
+	select {
+	case <-call.done:
+		// 结果在多个 Fact 间共享，返回副本以免写入相互影响
+		return deepCopyValue(call.val), call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Invalidate 删除指定实体的缓存结果
+func (c *LoaderCache) Invalidate(name, entity string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[loaderCacheKey{name: name, entity: entity}]; ok {
+		c.remove(elem)
+	}
+}
+
+// Stats 返回当前统计快照
+func (c *LoaderCache) Stats() LoaderCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Size = c.lru.Len()
+	return stats
+}
+
+func (c *LoaderCache) lookup(key loaderCacheKey) (*loaderCacheEntry, bool) {
+	// 调用方持有锁；过期条目直接删除
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*loaderCacheEntry)
+	if !time.Now().Before(entry.expires) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	if entry.err != nil {
+		c.stats.NegativeHits++
+	} else {
+		c.stats.Hits++
+	}
+	return entry, true
+}
+
+func (c *LoaderCache) load(ctx context.Context, key loaderCacheKey, call *loaderCall, load ContextLoader) {
+	// 共享加载脱离发起请求的取消，避免一个请求超时导致所有等待者失败
+	loadCtx := context.WithoutCancel(ctx)
+	if c.config.LoadTimeout > 0 {
+		var cancel context.CancelFunc
+		loadCtx, cancel = context.WithTimeout(loadCtx, c.config.LoadTimeout)
+		defer cancel()
+	}
+	defer func() {
+		// loader panic 时转为错误交给全部等待者，进行中的条目总会被清理，后续请求可以重新加载
+		if r := recover(); r != nil {
+			call.val, call.err = nil, fmt.Errorf("loader %s/%s panic: %v", key.name, key.entity, r)
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.inflight, key)
+		close(call.done)
+		switch {
+		case call.err == nil:
+			c.store(key, call.val, nil, c.config.TTL)
+		case errors.Is(call.err, ErrFactNotFound):
+			c.store(key, nil, call.err, c.config.NegativeTTL)
+		}
+	}()
+	call.val, call.err = load(loadCtx)
+}
+
+func (c *LoaderCache) store(key loaderCacheKey, val interface{}, err error, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	entry := &loaderCacheEntry{key: key, val: val, err: err, expires: time.Now().Add(ttl)}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.config.MaxEntries > 0 && c.lru.Len() > c.config.MaxEntries {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *LoaderCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*loaderCacheEntry).key)
+}
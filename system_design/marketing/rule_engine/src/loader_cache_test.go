@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoaderCacheHitsAndTTL(t *testing.T) {
+	cache := NewLoaderCache(LoaderCacheConfig{TTL: 30 * time.Millisecond})
+	var calls int32
+	load := func(context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return map[string]interface{}{"level": 2}, nil
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := cache.Get(context.Background(), "profile", "u1", load); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("loader called %d times", calls)
+	}
+	if stats := cache.Stats(); stats.Hits != 2 || stats.Misses != 1 || stats.Size != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if _, err := cache.Get(context.Background(), "profile", "u1", load); err != nil || calls != 2 {
+		t.Fatalf("expired entry not reloaded: calls = %d, err = %v", calls, err)
+	}
+}
+
+func TestLoaderCacheReturnsCopies(t *testing.T) {
+	cache := NewLoaderCache(LoaderCacheConfig{TTL: time.Minute})
+	load := func(context.Context) (interface{}, error) {
+		return map[string]interface{}{"level": 2}, nil
+	}
+	v, _ := cache.Get(context.Background(), "profile", "u1", load)
+	v.(map[string]interface{})["level"] = 99
+	v, _ = cache.Get(context.Background(), "profile", "u1", load)
+	if v.(map[string]interface{})["level"] != 2 {
+		t.Fatalf("cached value mutated: %v", v)
+	}
+}
+
+func TestLoaderCacheNegativeAndErrors(t *testing.T) {
+	cache := NewLoaderCache(LoaderCacheConfig{TTL: time.Minute})
+	var calls int32
+	notFound := func(context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, ErrFactNotFound
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Get(context.Background(), "profile", "missing", notFound); !errors.Is(err, ErrFactNotFound) {
+			t.Fatalf("err = %v", err)
+		}
+	}
+	if calls != 1 || cache.Stats().NegativeHits != 1 {
+		t.Fatalf("calls = %d, stats = %+v", calls, cache.Stats())
+	}
+	failing := func(context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, errors.New("backend down")
+	}
+	cache.Get(context.Background(), "profile", "down", failing)
+	cache.Get(context.Background(), "profile", "down", failing)
+	if calls != 3 {
+		t.Fatalf("errors should not be cached: calls = %d", calls)
+	}
+}
+
+func TestLoaderCacheLRUEviction(t *testing.T) {
+	cache := NewLoaderCache(LoaderCacheConfig{TTL: time.Minute, MaxEntries: 2})
+	load := func(context.Context) (interface{}, error) { return 1, nil }
+	cache.Get(context.Background(), "p", "a", load)
+	cache.Get(context.Background(), "p", "b", load)
+	cache.Get(context.Background(), "p", "a", load)
+	cache.Get(context.Background(), "p", "c", load)
+	stats := cache.Stats()
+	if stats.Evictions != 1 || stats.Size != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	// b 最久未使用，被淘汰
+	misses := stats.Misses
+	cache.Get(context.Background(), "p", "a", load)
+	cache.Get(context.Background(), "p", "b", load)
+	if got := cache.Stats().Misses - misses; got != 1 {
+		t.Fatalf("misses after eviction = %d", got)
+	}
+}
+
+func TestLoaderCacheLoaderPanic(t *testing.T) {
+	cache := NewLoaderCache(LoaderCacheConfig{TTL: time.Minute})
+	_, err := cache.Get(context.Background(), "profile", "u1", func(context.Context) (interface{}, error) {
+		panic("boom")
+	})
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("err = %v, want panic error", err)
+	}
+	// 进行中的条目已清理，后续请求重新加载而不是永久等待
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	v, err := cache.Get(ctx, "profile", "u1", func(context.Context) (interface{}, error) { return 1, nil })
+	if err != nil || v != 1 {
+		t.Fatalf("after panic = %v, %v", v, err)
+	}
+}
+
+func TestLoaderCacheStampedeProtection(t *testing.T) {
+	// go test -race：同键并发未命中只调用一次 loader
+	cache := NewLoaderCache(LoaderCacheConfig{TTL: time.Minute})
+	var calls int32
+	release := make(chan struct{})
+	load := func(context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return map[string]interface{}{"level": 2}, nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fact := NewFact(nil)
+			fact.SetContextLoader("user.profile", cache.Loader("profile", "u1", load))
+			if v, ok, err := fact.GetPath("user.profile.level"); !ok || err != nil || v != 2 {
+				t.Errorf("GetPath = %v, %v, %v", v, ok, err)
+			}
+		}()
+	}
+	for cache.Stats().Misses+cache.Stats().Coalesced < 16 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("loader called %d times", calls)
+	}
+}
+
+func TestLoaderCacheConcurrentPanics(t *testing.T) {
+	// go test -race：panic 的加载被所有等待者观察到，不会遗留进行中的条目
+	cache := NewLoaderCache(LoaderCacheConfig{TTL: time.Minute})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if _, err := cache.Get(ctx, "profile", "u1", func(context.Context) (interface{}, error) {
+				time.Sleep(time.Millisecond)
+				panic("boom")
+			}); err == nil || isContextError(err) {
+				t.Errorf("err = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func levelRule() []Rule {
+	return []Rule{{RuleID: "GOLD", Status: RuleStatusActive, Condition: &Condition{
+		Operator: "bitmask_all", Field: "user.level_mask", Value: LevelMaskGold,
+	}}}
+}
+
+func TestEvaluateCancelsLoaders(t *testing.T) {
+	fact := NewFact(nil)
+	canceled := make(chan error, 1)
+	fact.SetContextLoader("user.level_mask", func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		canceled <- ctx.Err()
+		return nil, ctx.Err()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := NewEngine(levelRule()).Evaluate(ctx, fact)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("evaluation took %v", elapsed)
+	}
+	if err := <-canceled; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("loader saw %v", err)
+	}
+}
+
+func TestLoaderTimeoutAndFallback(t *testing.T) {
+	slow := func(ctx context.Context) (interface{}, error) {
+		select {
+		case <-time.After(time.Second):
+			return LevelMaskGold, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	engine := NewEngine(levelRule())
+
+	fact := NewFact(nil)
+	fact.SetContextLoader("user.level_mask", slow, WithLoaderTimeout(10*time.Millisecond), WithLoaderFallback(LevelMaskGold))
+	results, err := engine.Evaluate(context.Background(), fact)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("fallback results = %v, %v", results, err)
+	}
+
+	fact = NewFact(nil)
+	fact.SetContextLoader("user.level_mask", slow, WithLoaderTimeout(10*time.Millisecond))
+	if _, err := engine.Evaluate(context.Background(), fact); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want loader timeout", err)
+	}
+
+	// 确认不存在的事实按缺失处理，不使用降级值
+	fact = NewFact(nil)
+	fact.SetContextLoader("user.level_mask", func(context.Context) (interface{}, error) {
+		return nil, ErrFactNotFound
+	}, WithLoaderFallback(LevelMaskGold))
+	if _, ok, err := fact.GetPath("user.level_mask"); ok || err != nil {
+		t.Fatalf("not found loader = %v, %v", ok, err)
+	}
+}
+
+func TestEvaluateDoesNotWaitForLoaderIgnoringContext(t *testing.T) {
+	release := make(chan struct{})
+	var finished int32
+	fact := NewFact(nil)
+	fact.SetContextLoader("user.level_mask", func(context.Context) (interface{}, error) {
+		<-release
+		atomic.StoreInt32(&finished, 1)
+		return LevelMaskGold, nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := NewEngine(levelRule()).Evaluate(ctx, fact); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v", err)
+	}
+	// 评估已返回，忽略 ctx 的 loader 仍在运行，直到自行返回
+	if atomic.LoadInt32(&finished) != 0 {
+		t.Fatal("loader should still be running")
+	}
+	close(release)
+}
+
+func TestQuantifierElementsInheritContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	parent := NewFact(nil).WithContext(ctx)
+	element := newElementFact(parent, map[string]interface{}{"price": 1})
+	if element.Context() != ctx {
+		t.Fatal("element fact should use the parent context")
+	}
+	scalar := newElementFact(parent, 1)
+	if scalar.Context() != ctx {
+		t.Fatal("scalar element fact should use the parent context")
+	}
+}
+
+func TestConcurrentEvaluateSharesLoads(t *testing.T) {
+	// go test -race：同一 Fact 被多个 goroutine 以不同 ctx 评估，loader 只执行一次
+	var calls int32
+	fact := NewFact(map[string]interface{}{"user": map[string]interface{}{"city": UserCityBeijing}})
+	fact.SetContextLoader("user.level_mask", func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(5 * time.Millisecond)
+		return LevelMaskGold, nil
+	})
+	engine := NewEngine(levelRule())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			results, err := engine.Evaluate(ctx, fact)
+			if err != nil || len(results) != 1 {
+				t.Errorf("results = %v, %v", results, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("loader called %d times", calls)
+	}
+}
+
+func TestPanickingLoaderWithCancelableContext(t *testing.T) {
+	// 可取消的上下文下 loader 在独立 goroutine 中执行，panic 转为评估错误而不是让进程崩溃
+	fact := NewFact(nil)
+	fact.SetContextLoader("user.level_mask", func(ctx context.Context) (interface{}, error) {
+		panic("boom")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err := NewEngine(levelRule()).Evaluate(ctx, fact)
+	if err == nil || !strings.Contains(err.Error(), "loader panic: boom") {
+		t.Fatalf("err = %v, want loader panic", err)
+	}
+}
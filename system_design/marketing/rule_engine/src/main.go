@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 )
 
 func main() {
@@ -11,14 +13,18 @@ func main() {
 	RegisterDefaultRules(cache)
 
 	rules := cache.GetAll()
-	runTargetingScenario(rules)
-	runPricingScenario(rules)
-	runRiskControlScenario(rules)
-	runTaskScenario(rules)
-	runTouchScenario(rules)
-	runRecoScenario(rules)
-	runAfterScenario(rules)
-	runPipelineScenario(rules)
+	// 规则只编译一次，各场景直接使用预先划分的分区
+	scenes := NewSceneEngine(rules)
+	runTargetingScenario(scenes)
+	runPricingScenario(scenes)
+	runRiskControlScenario(scenes)
+	runTaskScenario(scenes)
+	runTouchScenario(scenes)
+	runRecoScenario(scenes)
+	runAfterScenario(scenes)
+	runPipelineScenario(scenes)
+	runStructFactScenario(rules)
+	runLoaderTimeoutScenario(scenes)
 	runReteExample()
 }
 
@@ -31,7 +37,7 @@ func (h EligibilityHandler) Name() string {
 }
 
 func (h EligibilityHandler) Handle(ctx *PipelineContext) error {
-	results, err := h.engine.Evaluate(ctx.Fact)
+	results, err := h.engine.Evaluate(context.Background(), ctx.Fact)
 	if err != nil {
 		return err
 	}
@@ -85,80 +91,29 @@ func filterRulesByType(rules []Rule, ruleType string) []Rule {
 	return filtered
 }
 
-type evaluationEntry struct {
-	RuleID   string
-	RuleName string
-	Type     string
-	Priority int
-	Matched  bool
-	Reason   string
-	Actions  []Action
-}
-
-func buildEvaluationReport(rules []Rule, fact *Fact) []evaluationEntry {
-	copied := make([]Rule, len(rules))
-	copy(copied, rules)
-	sort.SliceStable(copied, func(i, j int) bool {
-		return copied[i].Priority > copied[j].Priority
-	})
-	mutexHit := map[string]bool{}
-	report := make([]evaluationEntry, 0, len(copied))
-	for _, rule := range copied {
-		entry := evaluationEntry{
-			RuleID:   rule.RuleID,
-			RuleName: rule.RuleName,
-			Type:     rule.Type,
-			Priority: rule.Priority,
-		}
-		if rule.Status != "" && strings.ToLower(rule.Status) != RuleStatusActive {
-			entry.Matched = false
-			entry.Reason = "inactive"
-			report = append(report, entry)
-			continue
-		}
-		if rule.MutexGroup != "" && mutexHit[rule.MutexGroup] {
-			entry.Matched = false
-			entry.Reason = "skipped_mutex_group"
-			report = append(report, entry)
-			continue
-		}
-		matched, err := EvaluateCondition(rule.Condition, fact)
-		if err != nil {
-			entry.Matched = false
-			entry.Reason = err.Error()
-			report = append(report, entry)
-			continue
-		}
-		if matched {
-			entry.Matched = true
-			entry.Reason = "matched"
-			entry.Actions = rule.Actions
-			if rule.MutexGroup != "" {
-				mutexHit[rule.MutexGroup] = true
-			}
-		} else {
-			entry.Matched = false
-			entry.Reason = "condition_false"
-		}
-		report = append(report, entry)
+func sceneEngine(scenes *SceneEngine, ruleType string) *Engine {
+	engine, ok := scenes.Type(ruleType)
+	if !ok {
+		// 类型没有规则时使用空引擎，输出与过滤结果为空时一致
+		return NewEngine(nil)
 	}
-	return report
+	return engine
 }
 
-func runScenario(title string, rules []Rule, fact *Fact) {
+func runScenario(title string, engine *Engine, fact *Fact) {
 	fmt.Println("=== " + title + " ===")
+	rules := engine.Rules()
 	printRules(rules)
 	printFact(fact)
-	printEvaluation(buildEvaluationReport(rules, fact))
-	engine := NewEngine(rules)
-	results, err := engine.Evaluate(fact)
+	results, trace, err := engine.EvaluateWithTrace(fact)
 	if err != nil {
 		panic(err)
 	}
+	printEvaluation(rules, trace)
 	printResults(results)
 }
 
-func runTargetingScenario(rules []Rule) {
+func runTargetingScenario(scenes *SceneEngine) {
 	fact := NewFact(map[string]interface{}{
 		"user": map[string]interface{}{
 			"register_days": 5,
@@ -169,10 +124,10 @@ func runTargetingScenario(rules []Rule) {
 			"total_amount": 320,
 		},
 	})
-	runScenario("targeting", filterRulesByType(rules, RuleTypeTargeting), fact)
+	runScenario("targeting", sceneEngine(scenes, RuleTypeTargeting), fact)
 }
 
-func runPricingScenario(rules []Rule) {
+func runPricingScenario(scenes *SceneEngine) {
 	fact := NewFact(map[string]interface{}{
 		"user": map[string]interface{}{
 			"level_mask": LevelMaskGold,
@@ -181,12 +136,16 @@ func runPricingScenario(rules []Rule) {
 			"total_amount": 200,
 			"threshold":    150,
 			"coupons_mask": CouponMaskPlatform + CouponMaskFullReduction,
+			"items": []interface{}{
+				map[string]interface{}{"sku": "SKU_APPLE", "category": ItemCategoryFresh, "price": 68},
+				map[string]interface{}{"sku": "SKU_TISSUE", "category": "日用", "price": 25},
+			},
 		},
 	})
-	runScenario("pricing", filterRulesByType(rules, RuleTypePricing), fact)
+	runScenario("pricing", sceneEngine(scenes, RuleTypePricing), fact)
 }
 
-func runRiskControlScenario(rules []Rule) {
+func runRiskControlScenario(scenes *SceneEngine) {
 	fact := NewFact(map[string]interface{}{
 		"risk": map[string]interface{}{
 			"daily_coupon_count": 3,
@@ -194,10 +153,10 @@ func runRiskControlScenario(rules []Rule) {
 			"device_blacklist":   true,
 		},
 	})
-	runScenario("risk_control", filterRulesByType(rules, RuleTypeRiskControl), fact)
+	runScenario("risk_control", sceneEngine(scenes, RuleTypeRiskControl), fact)
 }
 
-func runTaskScenario(rules []Rule) {
+func runTaskScenario(scenes *SceneEngine) {
 	fact := NewFact(map[string]interface{}{
 		"task": map[string]interface{}{
 			"checkin_streak":    3,
@@ -205,10 +164,10 @@ func runTaskScenario(rules []Rule) {
 			"first_order":       true,
 		},
 	})
-	runScenario("task", filterRulesByType(rules, RuleTypeTask), fact)
+	runScenario("task", sceneEngine(scenes, RuleTypeTask), fact)
 }
 
-func runTouchScenario(rules []Rule) {
+func runTouchScenario(scenes *SceneEngine) {
 	fact := NewFact(map[string]interface{}{
 		"user": map[string]interface{}{
 			"push_enabled":  false,
@@ -218,20 +177,20 @@ func runTouchScenario(rules []Rule) {
 			"message_count_24h": 1,
 		},
 	})
-	runScenario("touch", filterRulesByType(rules, RuleTypeTouch), fact)
+	runScenario("touch", sceneEngine(scenes, RuleTypeTouch), fact)
 }
 
-func runRecoScenario(rules []Rule) {
+func runRecoScenario(scenes *SceneEngine) {
 	fact := NewFact(map[string]interface{}{
 		"reco": map[string]interface{}{
 			"scene":          RecoSceneBigPromo,
 			"merchant_score": 4.5,
 		},
 	})
-	runScenario("reco", filterRulesByType(rules, RuleTypeReco), fact)
+	runScenario("reco", sceneEngine(scenes, RuleTypeReco), fact)
 }
 
-func runAfterScenario(rules []Rule) {
+func runAfterScenario(scenes *SceneEngine) {
 	fact := NewFact(map[string]interface{}{
 		"after": map[string]interface{}{
 			"credit_score":            650,
@@ -239,10 +198,10 @@ func runAfterScenario(rules []Rule) {
 			"delivery_delay_minutes": 35,
 		},
 	})
-	runScenario("after", filterRulesByType(rules, RuleTypeAfter), fact)
+	runScenario("after", sceneEngine(scenes, RuleTypeAfter), fact)
 }
 
-func runPipelineScenario(rules []Rule) {
+func runPipelineScenario(scenes *SceneEngine) {
 	fact := NewFact(map[string]interface{}{
 		"user": map[string]interface{}{
 			"register_days": 5,
@@ -254,8 +213,8 @@ func runPipelineScenario(rules []Rule) {
 			"threshold":    150,
 		},
 	})
-	runScenario("pipeline_rule_evaluation", rules, fact)
-	engine := NewEngine(rules)
+	engine := scenes.All()
+	runScenario("pipeline_rule_evaluation", engine, fact)
 	pipeline := NewPipeline(
 		EligibilityHandler{engine: engine},
 		BenefitHandler{},
@@ -272,6 +231,72 @@ func runPipelineScenario(rules []Rule) {
 	printPipelineOutput(ctx)
 }
 
+// CartItem、Cart、User 模拟业务服务中的结构体，字段通过 json 标签映射到规则路径
+type CartItem struct {
+	SKU      string  `json:"sku"`
+	Category string  `json:"category"`
+	Price    float64 `json:"price"`
+}
+
+type Cart struct {
+	TotalAmount int        `json:"total_amount"`
+	Items       []CartItem `json:"items"`
+}
+
+type User struct {
+	RegisterDays int      `json:"register_days"`
+	City         string   `json:"city"`
+	Tags         []string `json:"tags"`
+}
+
+func runStructFactScenario(rules []Rule) {
+	user := &User{RegisterDays: 3, City: UserCityShanghai, Tags: []string{UserTagHighValue}}
+	cart := &Cart{
+		TotalAmount: 150,
+		Items: []CartItem{
+			{SKU: "SKU_CHERRY", Category: ItemCategoryFresh, Price: 99},
+		},
+	}
+	// 结构体直接作为事实节点，无需转换为 map
+	fact := NewFact(map[string]interface{}{"user": user, "cart": cart})
+	scenarioRules := filterRulesByType(rules, RuleTypeTargeting)
+	for _, rule := range filterRulesByType(rules, RuleTypePricing) {
+		// 量词规则同样可以遍历结构体切片
+		if rule.RuleID == "RULE_PRICE_3" {
+			scenarioRules = append(scenarioRules, rule)
+		}
+	}
+	runScenario("struct_fact", NewEngine(scenarioRules), fact)
+}
+
+func runLoaderTimeoutScenario(scenes *SceneEngine) {
+	fmt.Println("=== loader_timeout ===")
+	fact := NewFact(map[string]interface{}{
+		"risk": map[string]interface{}{
+			"daily_coupon_count": 1,
+		},
+	})
+	// 模拟响应缓慢的黑名单服务：超过 loader 超时后按降级值 false 处理
+	slowBlacklist := func(ctx context.Context) (interface{}, error) {
+		select {
+		case <-time.After(time.Second):
+			return true, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	fact.SetContextLoader("risk.user_blacklist", slowBlacklist, WithLoaderTimeout(20*time.Millisecond), WithLoaderFallback(false))
+	fact.SetContextLoader("risk.device_blacklist", slowBlacklist, WithLoaderTimeout(20*time.Millisecond), WithLoaderFallback(false))
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	engine := sceneEngine(scenes, RuleTypeRiskControl)
+	results, err := engine.Evaluate(ctx, fact)
+	if err != nil {
+		panic(err)
+	}
+	printResults(results)
+}
+
 func runReteExample() {
 	rules := LoadRules()
 	runReteScenario("rete_targeting", filterRulesByType(rules, RuleTypeTargeting), NewFact(map[string]interface{}{
@@ -292,6 +317,9 @@ func runReteExample() {
 			"total_amount": 200,
 			"threshold":    150,
 			"coupons_mask": CouponMaskPlatform + CouponMaskFullReduction,
+			"items": []interface{}{
+				map[string]interface{}{"sku": "SKU_SALMON", "category": ItemCategoryFresh, "price": 128},
+			},
 		},
 	}))
 	runReteScenario("rete_risk_control", filterRulesByType(rules, RuleTypeRiskControl), NewFact(map[string]interface{}{
@@ -363,21 +391,55 @@ func printRules(rules []Rule) {
 
 func printFact(fact *Fact) {
 	fmt.Println("fact:")
-	printValue("  ", fact.data)
+	printValue("  ", fact.state.data)
 }
 
-func printEvaluation(report []evaluationEntry) {
+func printEvaluation(rules []Rule, trace *EvaluationTrace) {
 	fmt.Println("evaluation:")
-	if len(report) == 0 {
+	if len(trace.Rules) == 0 {
 		fmt.Println("  (empty)")
 		return
 	}
-	for _, entry := range report {
-		fmt.Printf("  - %s %s type=%s priority=%d matched=%t reason=%s\n", entry.RuleID, entry.RuleName, entry.Type, entry.Priority, entry.Matched, entry.Reason)
-		if len(entry.Actions) > 0 {
-			fmt.Printf("    actions: %s\n", formatActions(entry.Actions))
+	actions := map[string][]Action{}
+	for _, rule := range rules {
+		actions[rule.RuleID] = rule.Actions
+	}
+	for _, entry := range trace.Rules {
+		matched := entry.Outcome == RuleMatched
+		fmt.Printf("  - %s %s type=%s priority=%d matched=%t reason=%s\n", entry.RuleID, entry.RuleName, entry.Type, entry.Priority, matched, entry.Outcome)
+		if matched && len(actions[entry.RuleID]) > 0 {
+			fmt.Printf("    actions: %s\n", formatActions(actions[entry.RuleID]))
 		}
+		if entry.Outcome == RuleConditionFalse {
+			// 列出结果为假的叶子，说明未命中的原因
+			for _, leaf := range falseLeaves(entry.Condition, nil) {
+				fmt.Printf("    failed: %s\n", leaf)
+			}
+		}
+	}
+}
+
+func falseLeaves(node *NodeTrace, out []string) []string {
+	if node == nil || node.Outcome != NodeFalse {
+		return out
+	}
+	if len(node.Children) == 0 {
+		if node.Missing {
+			return append(out, fmt.Sprintf("%s %s %v (missing)", node.Field, node.Operator, node.Right))
+		}
+		if node.Field == "" {
+			return append(out, node.Operator)
+		}
+		return append(out, fmt.Sprintf("%s %s %v (left=%v)", node.Field, node.Operator, node.Right, node.Left))
+	}
+	if node.Operator == "NOT" || node.Operator == ConditionAll || node.Operator == ConditionAny || node.Operator == ConditionNone {
+		// 取反与量词的子节点结果不直接对应失败原因，只列出节点本身
+		return append(out, fmt.Sprintf("%s %s", node.Operator, node.Field))
 	}
+	for _, child := range node.Children {
+		out = falseLeaves(child, out)
+	}
+	return out
 }
 
 func printResults(results []Result) {
@@ -450,6 +512,11 @@ func formatCondition(condition *Condition) string {
 			return "NOT()"
 		}
 		return "NOT (" + formatCondition(&condition.Children[0]) + ")"
+	case ConditionAny, ConditionAll, ConditionNone:
+		if len(condition.Children) == 0 {
+			return op + " " + condition.Field + " ()"
+		}
+		return op + " " + condition.Field + " (" + formatCondition(&condition.Children[0]) + ")"
 	default:
 		return condition.Field + " " + condition.Operator + " " + formatValue(condition.Value)
 	}
@@ -1,15 +1,45 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"strings"
+	"sync"
 )
 
-// Fact 表示规则评估时的事实上下文，支持路径访问与懒加载
+// Fact 表示规则评估时的事实上下文，支持路径访问与懒加载。
+// Fact 及其视图可被多个 goroutine 并发读取：取值持读锁，loader 在锁外执行且同一 loader 只执行一次，结果持写锁写回。
+// 写入按路径替换进行，GetPath 返回的 map 与列表之后不会再被 Fact 修改，可以在锁外遍历。
 type Fact struct {
-	data    map[string]interface{}             // 已加载的事实数据
-	loaders map[string]func() (interface{}, error) // 路径级懒加载函数
-	loaded  map[string]bool                    // 路径是否已加载
+	state  *factState      // 数据与加载状态，视图之间共享
+	source interface{}     // 结构体事实根节点，data 中缺失的顶层字段从此读取
+	ctx    context.Context // 加载使用的上下文，为空时视为 context.Background
+
+	trace     *EvaluationTrace // 评估轨迹，为空时不记录
+	traceRule *RuleTrace       // 当前评估的规则，用于轨迹归属
+	traceNode *NodeTrace       // 当前执行的条件节点，子节点的记录挂在其下
+	// 本次评估中预取失败的路径，访问时直接返回该错误；只存在于评估视图上，不影响之后的评估
+	failed map[string]error
+}
+
+// factState 为 Fact 的可变状态
+type factState struct {
+	mu      sync.RWMutex
+	data    map[string]interface{} // 已加载的事实数据
+	loaders map[string]*factLoader // 路径级懒加载函数
+	loaded  map[string]bool        // 路径是否已加载
+	calls   *loadGroup             // 进行中的加载；并行评估的分组副本共享同一个保留结果的组
+	// recorder 非空时记录每次加载的结果；replay 为真时数据来自快照，忽略新注册的 loader
+	recorder *factRecorder
+	replay   bool
+	// SetPath/DeletePath 产生的变更集与观察者
+	changes   []FactChange
+	observers []FactObserver
+}
+
+// factAlloc 将 Fact 与其状态合并为一次分配，量词元素等临时 Fact 数量较多
+type factAlloc struct {
+	fact  Fact
+	state factState
 }
 
 // NewFact 创建 Fact，若 data 为空则初始化空数据集
@@ -17,72 +47,125 @@ func NewFact(data map[string]interface{}) *Fact {
 	if data == nil {
 		data = map[string]interface{}{}
 	}
-	return &Fact{
-		data:    data,
-		loaders: map[string]func() (interface{}, error){},
-		loaded:  map[string]bool{},
-	}
+	// loaders 与 loaded 在首次使用时再创建，减少量词元素等临时 Fact 的分配
+	alloc := &factAlloc{state: factState{data: data}}
+	alloc.fact.state = &alloc.state
+	return &alloc.fact
 }
 
-// SetLoader 为指定路径注册懒加载函数
+// SetLoader 为指定路径注册懒加载函数，路径按规范写法登记，a["b"] 与 a.b 等价
 func (f *Fact) SetLoader(path string, loader func() (interface{}, error)) {
-	f.loaders[path] = loader
+	f.setLoader(path, &factLoader{load: func(context.Context) (interface{}, error) {
+		return loader()
+	}})
 }
 
-// Clone 深拷贝 Fact 数据并复用 loader
-func (f *Fact) Clone() *Fact {
-	cloned := NewFact(deepCopyMap(f.data))
-	for k, v := range f.loaders {
-		cloned.loaders[k] = v
+func (f *Fact) setLoader(path string, loader *factLoader) {
+	st := f.state
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.replay {
+		return
+	}
+	if st.loaders == nil {
+		st.loaders = map[string]*factLoader{}
+	}
+	if st.calls == nil {
+		st.calls = newLoadGroup()
 	}
+	st.loaders[canonicalPath(path)] = loader
+}
+
+// Clone 深拷贝数据树并复用 loader，已加载的值随数据一并复制，克隆不会重新执行对应的 loader；
+// 调用方之后修改输入的 map 或取到的值，不会影响另一方
+func (f *Fact) Clone() *Fact {
+	st := f.state
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return f.cloneWith(deepCopyMap(st.data), newLoadGroup())
+}
+
+// share 返回与 f 共享数据树的副本，供一次并行评估的各分组使用：写入按路径替换，双方互不影响；
+// calls 为本次评估的加载组，分组之间复用彼此的加载结果，评估结束后随之丢弃
+func (f *Fact) share(calls *loadGroup) *Fact {
+	st := f.state
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return f.cloneWith(st.data, calls)
+}
+
+func (f *Fact) cloneWith(data map[string]interface{}, calls *loadGroup) *Fact {
+	// 调用方持有读锁
+	st := f.state
+	cloned := NewFact(data)
+	cloned.source = f.source
+	cloned.ctx = f.ctx
+	cloned.failed = f.failed
+	cs := cloned.state
+	cs.loaders = copyMap(st.loaders)
+	cs.loaded = copyMap(st.loaded)
+	cs.calls = calls
+	cs.recorder = st.recorder
+	cs.replay = st.replay
+	cs.changes = append([]FactChange(nil), st.changes...)
 	return cloned
 }
 
-// GetPath 通过点分路径访问数据，必要时触发懒加载
-func (f *Fact) GetPath(path string) (interface{}, bool, error) {
-	parts := strings.Split(path, ".")
-	var current interface{} = f.data
-	for i, part := range parts {
-		m, ok := current.(map[string]interface{})
-		if !ok {
-			return nil, false, nil
-		}
-		if val, ok := m[part]; ok {
-			current = val
-			continue
-		}
-		keyPath := strings.Join(parts[:i+1], ".")
-		loaded, err := f.loadInto(keyPath, m, part)
-		if err != nil {
-			return nil, false, err
-		}
-		if loaded {
-			if val, ok := m[part]; ok {
-				current = val
-				continue
-			}
-		}
-		return nil, false, nil
-	}
-	return current, true, nil
+func newEvaluationLoadGroup() *loadGroup {
+	// 并行评估期间保留成功结果的加载组
+	g := newLoadGroup()
+	g.retain = true
+	return g
 }
 
-// loadInto 将指定路径的数据加载到 parent 中
-func (f *Fact) loadInto(keyPath string, parent map[string]interface{}, key string) (bool, error) {
-	loader, ok := f.loaders[keyPath]
-	if !ok {
-		return false, nil
+func copyMap[K comparable, V any](src map[K]V) map[K]V {
+	if src == nil {
+		return nil
 	}
-	if f.loaded[keyPath] {
-		return true, nil
+	dst := make(map[K]V, len(src))
+	for k, v := range src {
+		dst[k] = v
 	}
-	val, err := loader()
+	return dst
+}
+
+// withTrace 返回共享数据与 loader 的视图，评估细节写入 trace
+func (f *Fact) withTrace(trace *EvaluationTrace) *Fact {
+	view := *f
+	view.trace = trace
+	view.traceRule = nil
+	view.traceNode = nil
+	return &view
+}
+
+// GetPath 按路径访问数据，支持 a.b、a[0]、a[*].b 与 ["a.b"]，必要时触发懒加载；
+// 与扩展路径语法之前一致，a..b 等非法路径视为字段不存在而不是错误
+func (f *Fact) GetPath(path string) (interface{}, bool, error) {
+	parsed, err := parseFactPath(path)
 	if err != nil {
-		return false, err
+		return nil, false, nil
+	}
+	return f.getPath(parsed)
+}
+
+func (f *Fact) getPath(path factPath) (interface{}, bool, error) {
+	st := f.state
+	for {
+		st.mu.RLock()
+		val, ok, blocked := f.walkPath(st.data, path, 0, true)
+		var site loadSite
+		if blocked {
+			site, blocked = f.loadTarget(path)
+		}
+		st.mu.RUnlock()
+		if !blocked {
+			return val, ok, nil
+		}
+		// 加载在锁外执行，完成后从根节点重新下行；每次加载都会标记 loader，循环必然结束
+		if err := f.load(site); err != nil {
+			return nil, false, err
+		}
 	}
-	parent[key] = val
-	f.loaded[keyPath] = true
-	return true, nil
 }
 
 func deepCopyMap(src map[string]interface{}) map[string]interface{} {
@@ -124,6 +207,8 @@ type Rule struct {
 	RuleName    string     `json:"rule_name"`
 	Description string     `json:"description"`
 	Type        string     `json:"type"`
+	Scene       string     `json:"scene,omitempty"` // 业务场景，与 Type、Tags 一起作为 SceneEngine 的路由键
+	Tags        []string   `json:"tags,omitempty"`
 	Priority    int        `json:"priority"`
 	MutexGroup  string     `json:"mutex_group"`
 	Status      string     `json:"status"`
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ChangeOp 为事实变更的类型
+type ChangeOp string
+
+const (
+	ChangeSet    ChangeOp = "set"
+	ChangeDelete ChangeOp = "delete"
+)
+
+// FactChange 记录一次路径变更，Path 为规范写法
+type FactChange struct {
+	Path   string
+	Op     ChangeOp
+	Old    interface{}
+	HadOld bool // 变更前路径是否存在
+	New    interface{}
+}
+
+// FactObserver 在变更写入后同步调用，调用时不持有 Fact 的锁，可以继续读取或修改 Fact
+type FactObserver func(change FactChange)
+
+// SetPath 将 value 写入 path，缺失的中间节点创建为 map；父节点有未触发的 loader 时先加载。
+// 路径的最后一段必须是字段名，不支持投影；写入会记录到变更集并通知观察者
+func (f *Fact) SetPath(path string, value interface{}) error {
+	segments, err := mutationSegments(path)
+	if err != nil {
+		return fmt.Errorf("set %s: %w", path, err)
+	}
+	return f.mutate(path, segments, ChangeSet, value)
+}
+
+// DeletePath 删除 path 指向的字段，返回删除前是否存在；删除成功后该路径上的 loader 不会再被触发，
+// 字段不存在时不影响尚未触发的 loader
+func (f *Fact) DeletePath(path string) (bool, error) {
+	segments, err := mutationSegments(path)
+	if err != nil {
+		return false, fmt.Errorf("delete %s: %w", path, err)
+	}
+	err = f.mutate(path, segments, ChangeDelete, nil)
+	if errors.Is(err, errNothingToDelete) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Observe 注册变更观察者；克隆出的 Fact 不继承观察者
+func (f *Fact) Observe(observer FactObserver) {
+	if observer == nil {
+		return
+	}
+	st := f.state
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.observers = append(st.observers, observer)
+}
+
+// Changes 返回自创建或上次 ClearChanges 以来的变更，按写入顺序排列
+func (f *Fact) Changes() []FactChange {
+	st := f.state
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return append([]FactChange(nil), st.changes...)
+}
+
+// ClearChanges 清空变更集并返回清空前的内容，供流水线逐阶段消费
+func (f *Fact) ClearChanges() []FactChange {
+	st := f.state
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	changes := st.changes
+	st.changes = nil
+	return changes
+}
+
+var errNothingToDelete = errors.New("path does not exist")
+
+func mutationSegments(path string) ([]pathSegment, error) {
+	parsed, err := parseFactPath(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, segment := range parsed.segments {
+		if segment.kind == segmentWildcard {
+			return nil, errors.New("projection is not writable")
+		}
+	}
+	if parsed.segments[len(parsed.segments)-1].kind != segmentKey {
+		return nil, errors.New("last segment must be a field")
+	}
+	return parsed.segments, nil
+}
+
+func (f *Fact) mutate(path string, segments []pathSegment, op ChangeOp, value interface{}) error {
+	last := len(segments) - 1
+	route := segments[:last]
+	if last > 0 {
+		// 先按正常取值触发父节点上的 loader，避免新建的中间节点遮蔽尚未加载的数据
+		if _, _, err := f.getPath(factPath{segments: route}); err != nil {
+			return fmt.Errorf("%s %s: %w", op, path, err)
+		}
+	}
+	st := f.state
+	st.mu.Lock()
+	parent := f.writable(route, op == ChangeSet)
+	if parent == nil {
+		st.mu.Unlock()
+		if op == ChangeDelete {
+			return errNothingToDelete
+		}
+		return fmt.Errorf("set %s: parent is not an object", path)
+	}
+	key := segments[last].key
+	change := FactChange{Path: formatSegments(segments), Op: op, New: value}
+	change.Old, change.HadOld = parent[key]
+	if op == ChangeSet {
+		parent[key] = value
+	} else {
+		delete(parent, key)
+	}
+	if _, ok := st.loaders[change.Path]; ok && (op == ChangeSet || change.HadOld) {
+		// 显式写入或删除的值优先于 loader；没有删除任何字段时 loader 仍可触发
+		f.markLoaded(change.Path)
+	}
+	if op == ChangeDelete && !change.HadOld {
+		st.mu.Unlock()
+		return errNothingToDelete
+	}
+	st.changes = append(st.changes, change)
+	observers := st.observers
+	st.mu.Unlock()
+	for _, observer := range observers {
+		observer(change)
+	}
+	return nil
+}
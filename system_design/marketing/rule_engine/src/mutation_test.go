@@ -0,0 +1,232 @@
+package main
+
+import (
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSetPathCreatesIntermediateMapsAndRecordsChanges(t *testing.T) {
+	fact := NewFact(map[string]interface{}{"user": map[string]interface{}{"city": UserCityBeijing}})
+	var observed []FactChange
+	fact.Observe(func(change FactChange) { observed = append(observed, change) })
+	if err := fact.SetPath("user.segment.level", "gold"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fact.SetPath(`user["city"]`, UserCityShanghai); err != nil {
+		t.Fatal(err)
+	}
+	if v, _, _ := fact.GetPath("user.segment.level"); v != "gold" {
+		t.Fatalf("level = %v", v)
+	}
+	want := []FactChange{
+		{Path: "user.segment.level", Op: ChangeSet, New: "gold"},
+		{Path: "user.city", Op: ChangeSet, Old: UserCityBeijing, HadOld: true, New: UserCityShanghai},
+	}
+	if !reflect.DeepEqual(observed, want) || !reflect.DeepEqual(fact.Changes(), want) {
+		t.Fatalf("observed = %+v, changes = %+v", observed, fact.Changes())
+	}
+	if cleared := fact.ClearChanges(); len(cleared) != 2 || len(fact.Changes()) != 0 {
+		t.Fatalf("cleared = %v, remaining = %v", cleared, fact.Changes())
+	}
+}
+
+func TestMutationErrors(t *testing.T) {
+	fact := NewFact(map[string]interface{}{"user": map[string]interface{}{"city": UserCityBeijing}})
+	for _, path := range []string{"user.city.name", "items[*].price", "items[0]", "a..b"} {
+		if err := fact.SetPath(path, 1); err == nil {
+			t.Fatalf("SetPath(%s): expected error", path)
+		}
+	}
+	if existed, err := fact.DeletePath("user.missing"); existed || err != nil {
+		t.Fatalf("delete missing = %v, %v", existed, err)
+	}
+	if len(fact.Changes()) != 0 {
+		t.Fatalf("changes = %v", fact.Changes())
+	}
+}
+
+func TestSetPathLoadsParentFirst(t *testing.T) {
+	fact := NewFact(nil)
+	fact.SetLoader("user", func() (interface{}, error) {
+		return map[string]interface{}{"city": UserCityBeijing}, nil
+	})
+	if err := fact.SetPath("user.level_mask", LevelMaskGold); err != nil {
+		t.Fatal(err)
+	}
+	if v, _, _ := fact.GetPath("user.city"); v != UserCityBeijing {
+		t.Fatalf("loaded sibling shadowed: %v", v)
+	}
+}
+
+func TestDeletePathLoaderMarking(t *testing.T) {
+	var calls int32
+	newFact := func() *Fact {
+		fact := NewFact(nil)
+		fact.SetLoader("user.level_mask", func() (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			return LevelMaskGold, nil
+		})
+		return fact
+	}
+	// 字段不存在时删除不影响尚未触发的 loader
+	fact := newFact()
+	if existed, err := fact.DeletePath("user.level_mask"); existed || err != nil {
+		t.Fatalf("delete = %v, %v", existed, err)
+	}
+	if v, ok, err := fact.GetPath("user.level_mask"); !ok || err != nil || v != LevelMaskGold {
+		t.Fatalf("loader suppressed by no-op delete: %v, %v, %v", v, ok, err)
+	}
+	// 实际删除后不再触发 loader
+	if existed, err := fact.DeletePath("user.level_mask"); !existed || err != nil {
+		t.Fatalf("delete = %v, %v", existed, err)
+	}
+	if _, ok, _ := fact.GetPath("user.level_mask"); ok || calls != 1 {
+		t.Fatalf("deleted path reloaded: ok = %v, calls = %d", ok, calls)
+	}
+	// 显式写入的值优先于 loader
+	fact = newFact()
+	fact.SetPath("user.level_mask", LevelMaskDiamond)
+	if v, _, _ := fact.GetPath("user.level_mask"); v != LevelMaskDiamond || calls != 1 {
+		t.Fatalf("level_mask = %v, calls = %d", v, calls)
+	}
+}
+
+func TestReteSessionApplyChanges(t *testing.T) {
+	rules := []Rule{
+		{RuleID: "GOLD", Priority: 2, Status: RuleStatusActive, Condition: &Condition{Operator: "bitmask_all", Field: "user.level_mask", Value: LevelMaskGold}},
+		{RuleID: "SAFE", Priority: 1, Status: RuleStatusActive, Condition: &Condition{Operator: "NOT", Children: []Condition{
+			{Operator: "eq", Field: "risk.user_blacklist", Value: true},
+		}}},
+	}
+	var evaluations int32
+	fact := NewFact(map[string]interface{}{"risk": map[string]interface{}{"user_blacklist": false}})
+	fact.SetLoader("user.level_mask", func() (interface{}, error) {
+		atomic.AddInt32(&evaluations, 1)
+		return LevelMaskGold, nil
+	})
+	session, err := NewReteEngine(rules).NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := session.Insert(fact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resultIDs(session.Results(id)); !reflect.DeepEqual(got, []string{"GOLD", "SAFE"}) {
+		t.Fatalf("results = %v", got)
+	}
+
+	fact.SetPath("risk.user_blacklist", true)
+	if err := session.ApplyChanges(id, fact.ClearChanges()); err != nil {
+		t.Fatal(err)
+	}
+	if got := resultIDs(session.Results(id)); !reflect.DeepEqual(got, []string{"GOLD"}) {
+		t.Fatalf("after blacklist = %v", got)
+	}
+	fact.SetPath("user.level_mask", 0)
+	fact.DeletePath("risk.user_blacklist")
+	if err := session.ApplyChanges(id, fact.ClearChanges()); err != nil {
+		t.Fatal(err)
+	}
+	if got := resultIDs(session.Results(id)); !reflect.DeepEqual(got, []string{"SAFE"}) {
+		t.Fatalf("after downgrade = %v", got)
+	}
+	session.Remove(id)
+	if got := session.Results(id); len(got) != 0 {
+		t.Fatalf("after remove = %v", got)
+	}
+	if err := session.ApplyChanges(id, nil); err == nil {
+		t.Fatal("expected error for removed fact")
+	}
+}
+
+func TestReteSessionMatchesEvaluate(t *testing.T) {
+	engine := NewReteEngine(LoadRules())
+	session, err := engine.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := session.Insert(benchmarkFact())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := engine.Evaluate(benchmarkFact())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(resultIDs(session.Results(id)), resultIDs(want)) {
+		t.Fatalf("session = %v, evaluate = %v", resultIDs(session.Results(id)), resultIDs(want))
+	}
+}
+
+func TestReteSessionApplyChangesOnListPaths(t *testing.T) {
+	// 变更路径与依赖路径逐段比较，下标（含负数）与投影之间视为匹配
+	rules := map[string]*Condition{
+		"quantifier": {Operator: "ANY", Field: "orders", Children: []Condition{{Operator: "gt", Field: "amount", Value: 100}}},
+		"projection": {Operator: "contains", Field: "orders[*].amount", Value: 500},
+		"index":      {Operator: "gt", Field: "orders[0].amount", Value: 100},
+	}
+	for name, condition := range rules {
+		for _, path := range []string{"orders[0].amount", "orders[-1].amount"} {
+			engine := NewReteEngine([]Rule{{RuleID: "r", Status: RuleStatusActive, Condition: condition}})
+			session, err := engine.NewSession()
+			if err != nil {
+				t.Fatal(err)
+			}
+			fact := NewFact(map[string]interface{}{"orders": []interface{}{map[string]interface{}{"amount": 10}}})
+			id, err := session.Insert(fact)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := session.Results(id); len(got) != 0 {
+				t.Fatalf("%s: initial = %v", name, resultIDs(got))
+			}
+			if err := fact.SetPath(path, 500); err != nil {
+				t.Fatal(err)
+			}
+			if err := session.ApplyChanges(id, fact.ClearChanges()); err != nil {
+				t.Fatal(err)
+			}
+			want, err := engine.Evaluate(fact)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := resultIDs(session.Results(id)); len(want) != 1 || !reflect.DeepEqual(got, resultIDs(want)) {
+				t.Fatalf("%s after %s: session = %v, evaluate = %v", name, path, got, resultIDs(want))
+			}
+		}
+	}
+}
+
+func TestPathsOverlap(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{"user", "user.level", true},
+		{"user.level", "user.level", true},
+		{"user.level", "user.levels", false},
+		{"user", "users", false},
+		{"orders[*].amount", "orders[0].amount", true},
+		{"orders[*].amount", "orders[-1]", true},
+		{"orders[0].amount", "orders[-1].amount", true},
+		{"orders[0].amount", "orders[1]", true},
+		{"orders[0].amount", "orders[0].status", false},
+		{"orders.amount", "orders[0].amount", false},
+		{`["a.b"].c`, "a.b.c", false},
+	}
+	for _, c := range cases {
+		a, err := parseFactPath(c.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := parseFactPath(c.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := pathsOverlap(a.segments, b.segments); got != c.want {
+			t.Fatalf("pathsOverlap(%s, %s) = %v", c.a, c.b, got)
+		}
+	}
+}
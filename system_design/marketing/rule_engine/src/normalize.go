@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// StringNormalization 描述字符串比较前的归一化步骤，按 NFKC、Trim、FoldCase、Aliases 的顺序执行
+type StringNormalization struct {
+	// NFKC 按 Unicode NFKC 规范化：全角字母数字与标点、全角空格、带圈数字等兼容字符折叠为标准形式
+	NFKC bool
+	// Trim 去除首尾空白
+	Trim bool
+	// FoldCase 忽略大小写
+	FoldCase bool
+	// Aliases 别名表，如 {"北京市": "北京"}，繁简体差异等也通过别名表归并
+	Aliases map[string]string
+}
+
+// stringNormalizer 为编译后的归一化器，别名表的键值已按相同步骤预处理
+type stringNormalizer struct {
+	config  StringNormalization
+	aliases map[string]string
+}
+
+func newStringNormalizer(config StringNormalization) *stringNormalizer {
+	n := &stringNormalizer{config: config}
+	if len(config.Aliases) > 0 {
+		n.aliases = make(map[string]string, len(config.Aliases))
+		for from, to := range config.Aliases {
+			n.aliases[n.fold(from)] = n.fold(to)
+		}
+	}
+	return n
+}
+
+func (n *stringNormalizer) fold(s string) string {
+	if n.config.NFKC {
+		s = norm.NFKC.String(s)
+	}
+	if n.config.Trim {
+		s = strings.TrimSpace(s)
+	}
+	if n.config.FoldCase {
+		s = strings.ToLower(s)
+	}
+	return s
+}
+
+func (n *stringNormalizer) normalize(s string) string {
+	s = n.fold(s)
+	if alias, ok := n.aliases[s]; ok {
+		return alias
+	}
+	return s
+}
+
+func (n *stringNormalizer) value(v interface{}) interface{} {
+	// 字符串直接归一化，列表逐元素归一化，其余类型保持不变
+	switch t := v.(type) {
+	case string:
+		return n.normalize(t)
+	case []interface{}:
+		normalized := make([]interface{}, len(t))
+		for i, item := range t {
+			if s, ok := item.(string); ok {
+				normalized[i] = n.normalize(s)
+				continue
+			}
+			normalized[i] = item
+		}
+		return normalized
+	case []string:
+		normalized := make([]string, len(t))
+		for i, item := range t {
+			normalized[i] = n.normalize(item)
+		}
+		return normalized
+	default:
+		return v
+	}
+}
+
+func normalizedOperator(operator string) bool {
+	// 字符串归一化仅作用于等值与包含类操作符
+	switch operator {
+	case "eq", "ne", "in", "contains":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStringNormalizerNFKC(t *testing.T) {
+	n := newStringNormalizer(StringNormalization{NFKC: true})
+	tests := map[string]string{
+		"ＡＢＣ１２３！": "ABC123!",
+		"北京　":     "北京 ",
+		"①":       "1",
+		"ｶﾀｶﾅ":    "カタカナ",
+		"ﬁle":     "file",
+		"㎏":       "kg",
+		"北京":      "北京",
+	}
+	for input, want := range tests {
+		if got := n.normalize(input); got != want {
+			t.Fatalf("normalize(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestStringNormalizerSteps(t *testing.T) {
+	n := newStringNormalizer(StringNormalization{
+		NFKC:     true,
+		Trim:     true,
+		FoldCase: true,
+		Aliases:  map[string]string{" 北京市 ": "北京", "ＳＨ": "上海"},
+	})
+	tests := map[string]string{
+		"  北京市　":  "北京",
+		"sh":      "上海",
+		" Ｈello ": "hello",
+	}
+	for input, want := range tests {
+		if got := n.normalize(input); got != want {
+			t.Fatalf("normalize(%q) = %q, want %q", input, got, want)
+		}
+	}
+	list := n.value([]interface{}{"北京市", 1}).([]interface{})
+	if list[0] != "北京" || list[1] != 1 {
+		t.Fatalf("list = %v", list)
+	}
+}
+
+func TestNormalizationAppliesToAllEvaluationPaths(t *testing.T) {
+	opts := []EngineOption{
+		WithFieldNormalization("user.city", StringNormalization{Trim: true, Aliases: map[string]string{"北京市": "北京"}}),
+		WithOperatorNormalization(ConditionEq, StringNormalization{NFKC: true, FoldCase: true}),
+	}
+	data := map[string]interface{}{
+		"user": map[string]interface{}{"city": " 北京市 ", "level": "ＧＯＬＤ", "tags": []interface{}{"VIP"}},
+	}
+	tests := []struct {
+		condition *Condition
+		want      bool
+	}{
+		{&Condition{Operator: "eq", Field: "user.city", Value: "北京"}, true},
+		{&Condition{Operator: "in", Field: "user.city", Value: []interface{}{"上海", "北京市"}}, true},
+		{&Condition{Operator: "contains", Field: "user.city", Value: "北京"}, true},
+		{&Condition{Operator: "eq", Field: "user.level", Value: "gold"}, true},
+		// 字段级配置优先于操作符级配置，user.city 不做大小写折叠
+		{&Condition{Operator: "eq", Field: "user.city", Value: "北京市 "}, true},
+		{&Condition{Operator: "gt", Field: "user.level", Value: 1}, false},
+		{&Condition{Operator: "eq", Field: "user.level", Value: map[string]interface{}{"var": "user.level"}}, true},
+	}
+	for _, tt := range tests {
+		rules := []Rule{{RuleID: "R", Status: RuleStatusActive, Condition: tt.condition}}
+		interpreted, _ := EvaluateCondition(tt.condition, NewFact(data), opts...)
+		results, _ := NewEngine(rules, opts...).Evaluate(context.Background(), NewFact(data))
+		rete, _ := NewReteEngine(rules, opts...).Evaluate(NewFact(data))
+		if interpreted != tt.want || (len(results) == 1) != tt.want || (len(rete) == 1) != tt.want {
+			t.Fatalf("%+v: interpreted %v, engine %v, rete %v; want %v", tt.condition, interpreted, results, rete, tt.want)
+		}
+	}
+	if ok, _ := EvaluateCondition(tests[0].condition, NewFact(data)); ok {
+		t.Fatal("without options the raw value should not match")
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import "strings"
+
+// EngineOption 定制引擎的编译与执行行为
+type EngineOption func(*engineOptions)
+
+// engineOptions 汇总引擎级配置，同一规则集共享
+type engineOptions struct {
+	// 数值比较模式与十进制精度
+	numeric NumericMode
+	decimal DecimalConfig
+	// 左右值类型不一致时的转换策略
+	coercion CoercionPolicy
+	// 字符串归一化配置，字段级优先于操作符级
+	fieldNormalization    map[string]StringNormalization
+	operatorNormalization map[string]StringNormalization
+	// 评估前是否并发预取规则依赖的 loader，以及同时执行的 loader 上限
+	prefetch            bool
+	prefetchParallelism int
+	// 是否为 EvaluateWith 记录逐节点的评估轨迹
+	tracing bool
+	// 单条规则执行出错时的处理方式
+	errorPolicy ErrorPolicy
+	// 默认执行模式，单次评估可通过 WithMode 覆盖
+	mode ExecutionMode
+	// 非空时开启 AND/OR 子条件的自适应重排
+	adaptive *AdaptiveConfig
+	// 关闭等值叶子上的判别索引
+	noRuleIndex bool
+}
+
+func newEngineOptions(opts []EngineOption) engineOptions {
+	options := engineOptions{
+		numeric:  NumericFloat,
+		decimal:  DefaultDecimalConfig,
+		coercion: CoercionStrict,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	return options
+}
+
+func (o engineOptions) comparator() comparator {
+	return comparator{numeric: o.numeric, decimal: o.decimal, coercion: o.coercion}
+}
+
+// WithDecimal 使用十进制定点比较数值，金额比较与折扣计算不再受浮点误差影响
+func WithDecimal(config DecimalConfig) EngineOption {
+	return func(o *engineOptions) {
+		o.numeric = NumericDecimal
+		o.decimal = config
+	}
+}
+
+// WithCoercion 设置类型转换策略，对所有比较操作符一致生效
+func WithCoercion(policy CoercionPolicy) EngineOption {
+	return func(o *engineOptions) {
+		o.coercion = policy
+	}
+}
+
+// WithFieldNormalization 为指定字段的 eq/ne/in/contains 比较设置字符串归一化
+func WithFieldNormalization(field string, normalization StringNormalization) EngineOption {
+	return func(o *engineOptions) {
+		if o.fieldNormalization == nil {
+			o.fieldNormalization = map[string]StringNormalization{}
+		}
+		o.fieldNormalization[field] = normalization
+	}
+}
+
+// WithOperatorNormalization 为指定操作符（eq/ne/in/contains）的所有比较设置字符串归一化
+func WithOperatorNormalization(operator string, normalization StringNormalization) EngineOption {
+	return func(o *engineOptions) {
+		if o.operatorNormalization == nil {
+			o.operatorNormalization = map[string]StringNormalization{}
+		}
+		o.operatorNormalization[strings.ToLower(operator)] = normalization
+	}
+}
+
+// WithPrefetch 在评估前按规则依赖的事实路径并发触发 loader，parallelism 为同时执行的上限（<=0 时不限制）
+func WithPrefetch(parallelism int) EngineOption {
+	return func(o *engineOptions) {
+		o.prefetch = true
+		o.prefetchParallelism = parallelism
+	}
+}
+
+// WithTracing 为 EvaluateWith 开启逐节点的评估轨迹，结果写入 Evaluation.Trace；
+// 轨迹由编译节点上的钩子记录，与普通评估执行同一份编译结果，未开启时每个节点只多一次判断
+func WithTracing() EngineOption {
+	return func(o *engineOptions) {
+		o.tracing = true
+	}
+}
+
+// WithErrorPolicy 设置单条规则执行出错时的处理方式，非 ErrorAbort 时其余规则继续执行，
+// 出错的规则记入 Evaluation.Errors
+func WithErrorPolicy(policy ErrorPolicy) EngineOption {
+	return func(o *engineOptions) {
+		o.errorPolicy = policy
+	}
+}
+
+// WithExecutionMode 设置规则集的默认执行模式，命中条数达到要求后停止执行剩余规则；mode<=0 时等同于 AllHits
+func WithExecutionMode(mode ExecutionMode) EngineOption {
+	return func(o *engineOptions) {
+		o.mode = mode.normalized()
+	}
+}
+
+// WithAdaptiveOrdering 按运行期采样的耗时（含 loader 延迟）与通过率重排 AND/OR 子条件，
+// 便宜且选择性高的子条件先执行；只移动不会出错的常量 eq/ne/in 叶子，结果与错误均与原顺序一致
+func WithAdaptiveOrdering(config AdaptiveConfig) EngineOption {
+	return func(o *engineOptions) {
+		config = config.normalized()
+		o.adaptive = &config
+	}
+}
+
+// WithoutRuleIndex 关闭等值叶子上的判别索引，所有规则逐条执行，用于对照与排查
+func WithoutRuleIndex() EngineOption {
+	return func(o *engineOptions) {
+		o.noRuleIndex = true
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// segmentKind 表示路径片段的类型
+type segmentKind int
+
+const (
+	segmentKey      segmentKind = iota // 对象字段，如 user 或 ["a.b"]
+	segmentIndex                       // 列表下标，如 [0]，负数从末尾计数
+	segmentWildcard                    // 列表投影，如 [*]
+)
+
+// pathSegment 为解析后的单个路径片段
+type pathSegment struct {
+	kind  segmentKind
+	key   string
+	index int
+	// flatten 为真时后续还有投影，子结果需要展开为一维列表
+	flatten bool
+}
+
+// factPath 为解析后的访问路径，编译期构建后可反复使用
+type factPath struct {
+	raw      string
+	segments []pathSegment
+	prefixes []string // prefixes[i] 为 segments[:i+1] 的规范写法，用于匹配 loader
+}
+
+var errEmptySegment = errors.New("empty path segment")
+
+// parseFactPath 解析路径语法：点分字段、[n] 下标、[*] 投影以及 ["a.b"] 或 ['a.b'] 形式的转义字段
+func parseFactPath(path string) (factPath, error) {
+	if path == "" {
+		return factPath{}, errors.New("empty path")
+	}
+	segments := make([]pathSegment, 0, strings.Count(path, ".")+strings.Count(path, "[")+1)
+	i := 0
+	for i < len(path) {
+		switch path[i] {
+		case '[':
+			segment, next, err := parseBracket(path, i)
+			if err != nil {
+				return factPath{}, err
+			}
+			segments = append(segments, segment)
+			i = next
+			continue
+		case '.':
+			if len(segments) == 0 {
+				return factPath{}, fmt.Errorf("invalid path %q: %w", path, errEmptySegment)
+			}
+			i++
+		default:
+			if len(segments) > 0 {
+				return factPath{}, fmt.Errorf("invalid path %q: missing '.' at %d", path, i)
+			}
+		}
+		start := i
+		for i < len(path) && path[i] != '.' && path[i] != '[' {
+			i++
+		}
+		if i == start {
+			return factPath{}, fmt.Errorf("invalid path %q: %w", path, errEmptySegment)
+		}
+		segments = append(segments, pathSegment{kind: segmentKey, key: path[start:i]})
+	}
+	wildcard := false
+	for i := len(segments) - 1; i >= 0; i-- {
+		if segments[i].kind == segmentWildcard {
+			segments[i].flatten = wildcard
+			wildcard = true
+		}
+	}
+	return factPath{raw: path, segments: segments}, nil
+}
+
+func parseBracket(path string, start int) (pathSegment, int, error) {
+	// start 指向 '['，返回片段与 ']' 之后的位置
+	i := start + 1
+	if i < len(path) && (path[i] == '"' || path[i] == '\'') {
+		key, end, err := parseQuoted(path, i)
+		if err != nil {
+			return pathSegment{}, 0, err
+		}
+		if end >= len(path) || path[end] != ']' {
+			return pathSegment{}, 0, fmt.Errorf("invalid path %q: missing ']' at %d", path, end)
+		}
+		return pathSegment{kind: segmentKey, key: key}, end + 1, nil
+	}
+	end := strings.IndexByte(path[i:], ']')
+	if end < 0 {
+		return pathSegment{}, 0, fmt.Errorf("invalid path %q: missing ']'", path)
+	}
+	content := path[i : i+end]
+	if content == "*" {
+		return pathSegment{kind: segmentWildcard}, i + end + 1, nil
+	}
+	index, err := strconv.Atoi(content)
+	if err != nil {
+		return pathSegment{}, 0, fmt.Errorf("invalid path %q: bad index %q", path, content)
+	}
+	return pathSegment{kind: segmentIndex, index: index}, i + end + 1, nil
+}
+
+func parseQuoted(path string, start int) (string, int, error) {
+	// 双引号遵循 Go 字符串转义，单引号仅支持 \' 与 \\
+	quote := path[start]
+	var b strings.Builder
+	for i := start + 1; i < len(path); i++ {
+		c := path[i]
+		if c == '\\' && i+1 < len(path) {
+			if quote == '\'' {
+				b.WriteByte(path[i+1])
+			}
+			i++
+			continue
+		}
+		if c != quote {
+			if quote == '\'' {
+				b.WriteByte(c)
+			}
+			continue
+		}
+		if quote == '\'' {
+			return b.String(), i + 1, nil
+		}
+		key, err := strconv.Unquote(path[start : i+1])
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid path %q: %v", path, err)
+		}
+		return key, i + 1, nil
+	}
+	return "", 0, fmt.Errorf("invalid path %q: unterminated quote", path)
+}
+
+func newFactPath(path string) (factPath, error) {
+	// 编译期路径预先计算全部前缀，运行期访问不再拼接字符串
+	parsed, err := parseFactPath(path)
+	if err != nil {
+		return factPath{}, err
+	}
+	parsed.prefixes = make([]string, len(parsed.segments))
+	for i := range parsed.segments {
+		parsed.prefixes[i] = formatSegments(parsed.segments[:i+1])
+	}
+	return parsed, nil
+}
+
+func (p factPath) prefix(i int) string {
+	if p.prefixes != nil {
+		return p.prefixes[i]
+	}
+	return formatSegments(p.segments[:i+1])
+}
+
+func formatSegments(segments []pathSegment) string {
+	// 生成规范写法：普通字段以点连接，含特殊字符的字段加引号
+	var b strings.Builder
+	for i, segment := range segments {
+		switch segment.kind {
+		case segmentIndex:
+			b.WriteString("[" + strconv.Itoa(segment.index) + "]")
+		case segmentWildcard:
+			b.WriteString("[*]")
+		default:
+			if strings.ContainsAny(segment.key, ".[]\"'") {
+				b.WriteString("[" + strconv.Quote(segment.key) + "]")
+				continue
+			}
+			if i > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(segment.key)
+		}
+	}
+	return b.String()
+}
+
+func canonicalPath(path string) string {
+	// loader 注册路径统一为规范写法，无法解析时保持原样
+	parsed, err := parseFactPath(path)
+	if err != nil {
+		return path
+	}
+	return formatSegments(parsed.segments)
+}
+
+func listValue(v interface{}) (reflect.Value, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return reflect.Value{}, false
+	}
+	return rv, true
+}
+
+func (f *Fact) walkPath(current interface{}, path factPath, start int, loadable bool) (interface{}, bool, bool) {
+	// 调用方持有读锁；loadable 表示仍在从根节点直接下行，只有这时路径前缀才能匹配 loader。
+	// 第三个返回值表示在缺失节点处可能存在 loader，需要由调用方加载后重试
+	for i := start; i < len(path.segments); i++ {
+		segment := path.segments[i]
+		switch segment.kind {
+		case segmentIndex:
+			list, ok := listValue(current)
+			if !ok {
+				return nil, false, false
+			}
+			index := segment.index
+			if index < 0 {
+				index += list.Len()
+			}
+			if index < 0 || index >= list.Len() {
+				return nil, false, false
+			}
+			current = list.Index(index).Interface()
+			continue
+		case segmentWildcard:
+			list, ok := listValue(current)
+			if !ok {
+				return nil, false, false
+			}
+			// 投影：对每个元素求剩余路径，缺失的元素跳过
+			projected := make([]interface{}, 0, list.Len())
+			for j := 0; j < list.Len(); j++ {
+				val, ok, _ := f.walkPath(list.Index(j).Interface(), path, i+1, false)
+				if !ok {
+					continue
+				}
+				if nested, isList := val.([]interface{}); isList && segment.flatten {
+					projected = append(projected, nested...)
+					continue
+				}
+				projected = append(projected, val)
+			}
+			return projected, true, false
+		}
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			// 结构体等非 map 节点通过反射或访问器读取
+			val, ok := lookupField(current, segment.key)
+			if !ok {
+				return nil, false, false
+			}
+			current = val
+			continue
+		}
+		if val, ok := m[segment.key]; ok {
+			current = val
+			continue
+		}
+		if i == 0 && loadable && f.source != nil {
+			if val, ok := lookupField(f.source, segment.key); ok {
+				current = val
+				continue
+			}
+		}
+		return nil, false, loadable && len(f.state.loaders) > 0
+	}
+	return current, true, false
+}
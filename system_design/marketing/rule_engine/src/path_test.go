@@ -0,0 +1,113 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func pathTestFact() *Fact {
+	return NewFact(pathTestData())
+}
+
+func pathTestData() map[string]interface{} {
+	return map[string]interface{}{
+		"cart": map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"sku": "A", "price": 10, "tags": []interface{}{"x", "y"}},
+				map[string]interface{}{"sku": "B", "price": 20, "tags": []interface{}{"z"}},
+				map[string]interface{}{"sku": "C"},
+			},
+		},
+		"ext":      map[string]interface{}{"a.b": "dotted", "it's": "quoted"},
+		"matrix":   []interface{}{[]interface{}{1, 2}, []interface{}{3}},
+		"a":        map[string]interface{}{"": "empty key"},
+		"profiles": []map[string]interface{}{{"city": "北京"}},
+	}
+}
+
+func TestGetPathSyntax(t *testing.T) {
+	fact := pathTestFact()
+	tests := []struct {
+		path string
+		want interface{}
+		ok   bool
+	}{
+		{"cart.items[0].sku", "A", true},
+		{"cart.items[-1].sku", "C", true},
+		{"cart.items[3].sku", nil, false},
+		{"cart.items[*].price", []interface{}{10, 20}, true},
+		{"cart.items[*].tags[*]", []interface{}{"x", "y", "z"}, true},
+		{"matrix[*][0]", []interface{}{1, 3}, true},
+		{`ext["a.b"]`, "dotted", true},
+		{`ext['it\'s']`, "quoted", true},
+		{"ext.a.b", nil, false},
+		{"profiles[0].city", "北京", true},
+		{"cart.items.sku", nil, false},
+		{"cart[0]", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok, err := fact.GetPath(tt.path)
+		if err != nil {
+			t.Fatalf("GetPath(%q): %v", tt.path, err)
+		}
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("GetPath(%q) = %v, %v; want %v, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMalformedPaths(t *testing.T) {
+	fact := pathTestFact()
+	malformed := []string{"", "a..b", ".a", "a.", "a[x]", "a[", `a["b`, "a[1"}
+	for _, path := range malformed {
+		if _, err := parseFactPath(path); err == nil {
+			t.Fatalf("parseFactPath(%q) should fail", path)
+		}
+		// GetPath 与扩展语法之前一致：非法路径按字段不存在处理
+		got, ok, err := fact.GetPath(path)
+		if err != nil || ok || got != nil {
+			t.Fatalf("GetPath(%q) = %v, %v, %v; want not found", path, got, ok, err)
+		}
+		if path != "" {
+			if _, err := CompileCondition(&Condition{Operator: "eq", Field: path, Value: 1}); err == nil {
+				t.Fatalf("CompileCondition with field %q should fail", path)
+			}
+		}
+		if err := fact.SetPath(path, 1); err == nil {
+			t.Fatalf("SetPath(%q) should fail", path)
+		}
+	}
+}
+
+func TestPathsInConditionsAndVarRefs(t *testing.T) {
+	conditions := []*Condition{
+		{Operator: "eq", Field: "cart.items[1].price", Value: 20},
+		{Operator: "contains", Field: "cart.items[*].sku", Value: "C"},
+		{Operator: "eq", Field: `ext["a.b"]`, Value: "dotted"},
+		{Operator: "lt", Field: "cart.items[0].price", Value: map[string]interface{}{"var": "cart.items[1].price"}},
+	}
+	for _, condition := range conditions {
+		got, err := evaluateAllPaths(t, condition, pathTestData())
+		if err != nil || !got {
+			t.Fatalf("%+v = %v, %v", condition, got, err)
+		}
+	}
+}
+
+func TestLoaderOnQuotedPath(t *testing.T) {
+	fact := NewFact(nil)
+	calls := 0
+	fact.SetLoader(`ext["a.b"]`, func() (interface{}, error) {
+		calls++
+		return "loaded", nil
+	})
+	for _, path := range []string{`ext["a.b"]`, `ext['a.b']`} {
+		v, ok, err := fact.GetPath(path)
+		if err != nil || !ok || v != "loaded" {
+			t.Fatalf("GetPath(%q) = %v, %v, %v", path, v, ok, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("loader called %d times", calls)
+	}
+}
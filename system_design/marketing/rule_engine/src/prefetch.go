@@ -0,0 +1,212 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ConditionDependencies 返回条件树引用的全部事实路径（字段与 var 引用），已去重并排序。
+// 量词子条件中的字段相对于列表元素解析，只计入量词的列表路径。
+func ConditionDependencies(condition *Condition) []string {
+	seen := map[string]bool{}
+	collectDependencies(condition, seen)
+	return sortedKeys(seen)
+}
+
+func collectDependencies(condition *Condition, seen map[string]bool) {
+	if condition == nil {
+		return
+	}
+	switch strings.ToUpper(condition.Operator) {
+	case "AND", "OR", "NOT":
+		for i := range condition.Children {
+			collectDependencies(&condition.Children[i], seen)
+		}
+	case ConditionAny, ConditionAll, ConditionNone:
+		if condition.Field != "" {
+			seen[condition.Field] = true
+		}
+	default:
+		if condition.Field != "" {
+			seen[condition.Field] = true
+		}
+		if ref, ok := varRef(condition.Value); ok {
+			seen[ref] = true
+		}
+	}
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func ruleDependencies(rules []Rule) []factPath {
+	// 汇总激活规则的依赖路径，非法路径在编译阶段已被剔除，这里直接忽略
+	seen := map[string]bool{}
+	for i := range rules {
+		if rules[i].Status != "" && strings.ToLower(rules[i].Status) != "active" {
+			continue
+		}
+		collectDependencies(rules[i].Condition, seen)
+	}
+	paths := make([]factPath, 0, len(seen))
+	for _, raw := range sortedKeys(seen) {
+		if path, err := newFactPath(raw); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// Prefetch 并发触发 paths 所需的 loader，parallelism 限制同时执行的 loader 数量（<=0 时不限制）。
+// 同一 loader 只执行一次；嵌套 loader 按层级分轮执行。加载失败不会中断预取，
+// 失败的 loader 在之后访问该路径时重新执行。
+func (f *Fact) Prefetch(paths []string, parallelism int) error {
+	parsed := make([]factPath, 0, len(paths))
+	for _, raw := range paths {
+		path, err := newFactPath(raw)
+		if err != nil {
+			return err
+		}
+		parsed = append(parsed, path)
+	}
+	f.prefetch(parsed, parallelism)
+	return nil
+}
+
+func (f *Fact) prefetch(paths []factPath, parallelism int) *Fact {
+	// 返回用于本次评估的视图：存在失败的 loader 时，视图记录这些错误，
+	// 评估访问到对应路径时直接返回，不再重复调用；失败不写入共享状态，之后的评估会重新加载
+	st := f.state
+	if len(paths) == 0 {
+		return f
+	}
+	var failed map[string]error
+	for {
+		// 每轮收集各路径上第一个缺失且有 loader 的节点，锁外并发加载，各自持写锁写回
+		pending := map[string]loadSite{}
+		st.mu.RLock()
+		if len(st.loaders) == 0 {
+			st.mu.RUnlock()
+			break
+		}
+		for _, path := range paths {
+			// 本次评估中已失败的 loader 不再重试
+			if site, ok := f.loadTarget(path); ok && f.failed[site.keyPath] == nil && failed[site.keyPath] == nil {
+				pending[site.keyPath] = site
+			}
+		}
+		st.mu.RUnlock()
+		if len(pending) == 0 {
+			break
+		}
+		for keyPath, err := range f.runLoaders(pending, parallelism) {
+			if failed == nil {
+				failed = map[string]error{}
+			}
+			failed[keyPath] = err
+		}
+	}
+	if len(failed) == 0 {
+		return f
+	}
+	for keyPath, err := range f.failed {
+		failed[keyPath] = err
+	}
+	view := *f
+	view.failed = failed
+	return &view
+}
+
+func (f *Fact) runLoaders(pending map[string]loadSite, parallelism int) map[string]error {
+	// 普通 loader 各自执行，同一批量 loader 的成员合并为一次调用；返回失败的路径与错误
+	var tasks []func() map[string]error
+	batches := map[*batchGroup]map[string]loadSite{}
+	for keyPath, site := range pending {
+		if site.loader.batch != nil {
+			if batches[site.loader.batch] == nil {
+				batches[site.loader.batch] = map[string]loadSite{}
+			}
+			batches[site.loader.batch][keyPath] = site
+			continue
+		}
+		tasks = append(tasks, f.fetchTask(site.loader, map[string]loadSite{keyPath: site}))
+	}
+	for _, sites := range batches {
+		// 以路径最小的成员作为单飞键，保证同一组成员得到确定的键
+		first := ""
+		for path := range sites {
+			if first == "" || path < first {
+				first = path
+			}
+		}
+		tasks = append(tasks, f.fetchTask(sites[first].loader, sites))
+	}
+	if parallelism <= 0 || parallelism > len(tasks) {
+		parallelism = len(tasks)
+	}
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed = map[string]error{}
+		slots  = make(chan struct{}, parallelism)
+	)
+	for _, task := range tasks {
+		task := task
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			errs := task()
+			mu.Lock()
+			for keyPath, err := range errs {
+				failed[keyPath] = err
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return failed
+}
+
+func (f *Fact) fetchTask(key *factLoader, sites map[string]loadSite) func() map[string]error {
+	// 加载一组落点，成功结果持写锁写回，失败时返回各落点的错误
+	return func() map[string]error {
+		st := f.state
+		members := make(map[string]*factLoader, len(sites))
+		for keyPath, site := range sites {
+			members[keyPath] = site.loader
+		}
+		resolved, err := f.fetch(key, members, func(resolved map[string]batchValue) {
+			st.mu.Lock()
+			defer st.mu.Unlock()
+			for keyPath, value := range resolved {
+				if site, ok := sites[keyPath]; ok {
+					f.storeValue(site, value)
+				}
+			}
+		})
+		if rec := st.recorder; rec != nil {
+			for keyPath, member := range members {
+				if value, ok := resolved[keyPath]; ok || err != nil {
+					rec.record(keyPath, member, value, err)
+				}
+			}
+		}
+		if err == nil {
+			return nil
+		}
+		errs := make(map[string]error, len(sites))
+		for keyPath := range sites {
+			errs[keyPath] = err
+		}
+		return errs
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func prefetchRules() []Rule {
+	return []Rule{activeRule("PREFETCH", 0, and(
+		leaf("gt", "user.register_days", 0),
+		leaf("gt", "cart.total_amount", 0),
+		leaf("eq", "risk.user_blacklist", false),
+	))}
+}
+
+func TestPrefetchRunsLoadersConcurrently(t *testing.T) {
+	fact := NewFact(nil)
+	var calls int32
+	slow := func(v interface{}) func() (interface{}, error) {
+		return func() (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(30 * time.Millisecond)
+			return v, nil
+		}
+	}
+	fact.SetLoader("user.register_days", slow(5))
+	fact.SetLoader("cart.total_amount", slow(100))
+	fact.SetLoader("risk.user_blacklist", slow(false))
+	engine := NewEngine(prefetchRules(), WithPrefetch(0))
+	start := time.Now()
+	results, err := engine.Evaluate(context.Background(), fact)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("results = %v, %v", results, err)
+	}
+	if elapsed := time.Since(start); elapsed > 80*time.Millisecond {
+		t.Fatalf("prefetch took %v, loaders did not run concurrently", elapsed)
+	}
+	if calls != 3 {
+		t.Fatalf("loaders called %d times", calls)
+	}
+}
+
+func TestPrefetchFailureScopedToEvaluation(t *testing.T) {
+	// 失败只在本次评估内复用，下一次评估重新调用 loader
+	fact := NewFact(map[string]interface{}{
+		"cart": map[string]interface{}{"total_amount": 100},
+		"risk": map[string]interface{}{"user_blacklist": false},
+	})
+	var calls int32
+	fact.SetLoader("user.register_days", func() (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return nil, errors.New("temporary failure")
+		}
+		return 5, nil
+	})
+	engine := NewEngine(prefetchRules(), WithPrefetch(0))
+	if _, err := engine.Evaluate(context.Background(), fact); err == nil {
+		t.Fatal("first evaluation should return the loader error")
+	}
+	if calls != 1 {
+		t.Fatalf("failed loader re-run within one evaluation: %d calls", calls)
+	}
+	results, err := engine.Evaluate(context.Background(), fact)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("second evaluation = %v, %v", results, err)
+	}
+	if calls != 2 {
+		t.Fatalf("loader called %d times", calls)
+	}
+}
+
+func TestPublicPrefetchDoesNotRememberFailures(t *testing.T) {
+	fact := NewFact(nil)
+	var calls int32
+	fact.SetLoader("user.register_days", func() (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return nil, errors.New("temporary failure")
+		}
+		return 5, nil
+	})
+	if err := fact.Prefetch([]string{"user.register_days"}, 1); err != nil {
+		t.Fatal(err)
+	}
+	value, ok, err := fact.GetPath("user.register_days")
+	if err != nil || !ok || value != 5 {
+		t.Fatalf("GetPath = %v, %v, %v", value, ok, err)
+	}
+}
+
+func TestConcurrentPrefetchEvaluations(t *testing.T) {
+	// go test -race：多个 goroutine 同时对同一 Fact 预取并评估，失败的 loader 不污染其他评估
+	fact := NewFact(map[string]interface{}{"risk": map[string]interface{}{"user_blacklist": false}})
+	var calls int32
+	fact.SetLoader("user.register_days", func() (interface{}, error) { return 5, nil })
+	fact.SetLoader("cart.total_amount", func() (interface{}, error) {
+		if atomic.AddInt32(&calls, 1)%2 == 1 {
+			return nil, errors.New("flaky")
+		}
+		return 100, nil
+	})
+	engine := NewEngine(prefetchRules(), WithPrefetch(2))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if results, err := engine.Evaluate(context.Background(), fact); err == nil && len(results) != 1 {
+					t.Errorf("results = %v", results)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	results, err := engine.Evaluate(context.Background(), fact)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("final evaluation = %v, %v", results, err)
+	}
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"sort"
 	"strings"
 	"sync"
@@ -10,22 +12,27 @@ import (
 
 // ReteEngine 负责构建网络并对外提供规则评估入口
 type ReteEngine struct {
-	rules []Rule
+	rules   []Rule
+	options engineOptions
+	deps    []factPath
 }
 
 // NewReteEngine 预排序规则，确保优先级语义与 Engine 一致
-func NewReteEngine(rules []Rule) *ReteEngine {
+func NewReteEngine(rules []Rule, opts ...EngineOption) *ReteEngine {
 	copied := make([]Rule, len(rules))
 	copy(copied, rules)
 	sort.SliceStable(copied, func(i, j int) bool {
 		return copied[i].Priority > copied[j].Priority
 	})
-	return &ReteEngine{rules: copied}
+	return &ReteEngine{rules: copied, options: newEngineOptions(opts), deps: ruleDependencies(copied)}
 }
 
 // Evaluate 构建会话并插入单个事实完成评估
 func (e *ReteEngine) Evaluate(fact *Fact) ([]Result, error) {
-	session, err := newReteSession(e.rules)
+	if e.options.prefetch {
+		fact = fact.prefetch(e.deps, e.options.prefetchParallelism)
+	}
+	session, err := newReteSession(e.rules, newConditionCompiler(e.options))
 	if err != nil {
 		return nil, err
 	}
@@ -36,6 +43,60 @@ func (e *ReteEngine) Evaluate(fact *Fact) ([]Result, error) {
 	return session.ResultsForFact(factID), nil
 }
 
+// EvaluateContext 与 Evaluate 相同，插入事实时触发的 loader 受 ctx 约束
+func (e *ReteEngine) EvaluateContext(ctx context.Context, fact *Fact) ([]Result, error) {
+	return e.Evaluate(fact.WithContext(ctx))
+}
+
+// ReteSession 为长期持有的 Rete 会话：插入的事实保留在网络中，事实经 SetPath/DeletePath 修改后
+// 调用 ApplyChanges 只重新评估受影响的条件，不必重建网络。会话可被多个 goroutine 并发调用
+type ReteSession struct {
+	mu      sync.Mutex
+	session *reteSession
+	engine  *ReteEngine
+}
+
+// NewSession 按引擎的规则与配置构建会话
+func (e *ReteEngine) NewSession() (*ReteSession, error) {
+	session, err := newReteSession(e.rules, newConditionCompiler(e.options))
+	if err != nil {
+		return nil, err
+	}
+	return &ReteSession{session: session, engine: e}, nil
+}
+
+// Insert 插入事实并返回其在会话中的编号。开启预取时先并发加载依赖路径，
+// 预取失败的 loader 在评估访问时重新执行
+func (s *ReteSession) Insert(fact *Fact) (int, error) {
+	if s.engine.options.prefetch {
+		fact.prefetch(s.engine.deps, s.engine.options.prefetchParallelism)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.session.InsertFact(fact)
+}
+
+// ApplyChanges 按变更集增量更新事实 id 的命中结果，通常传入 fact.ClearChanges() 的返回值
+func (s *ReteSession) ApplyChanges(id int, changes []FactChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.session.ApplyChanges(id, changes)
+}
+
+// Remove 撤回事实及其全部激活
+func (s *ReteSession) Remove(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.session.RemoveFact(id)
+}
+
+// Results 按规则优先级返回事实 id 当前的命中结果，互斥组内只保留第一条
+func (s *ReteSession) Results(id int) []Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.session.ResultsForFact(id)
+}
+
 // EvaluateParallel 按规则分组并行评估，每组独立会话避免状态冲突
 func (e *ReteEngine) EvaluateParallel(fact *Fact, groupKey func(Rule) string) ([]Result, error) {
 	if groupKey == nil {
@@ -46,6 +107,10 @@ func (e *ReteEngine) EvaluateParallel(fact *Fact, groupKey func(Rule) string) ([
 			return rule.Type
 		}
 	}
+	if e.options.prefetch {
+		// 预取在分组前完成，分组引擎不再重复预取
+		fact = fact.prefetch(e.deps, e.options.prefetchParallelism)
+	}
 	groups := map[string][]Rule{}
 	for _, rule := range e.rules {
 		key := groupKey(rule)
@@ -57,13 +122,16 @@ func (e *ReteEngine) EvaluateParallel(fact *Fact, groupKey func(Rule) string) ([
 		results  []Result
 		firstErr error
 	)
+	// 各分组共享数据树与本次评估的加载组，同一 loader 在分组之间只执行一次
+	calls := newEvaluationLoadGroup()
 	for _, rules := range groups {
 		groupRules := rules
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			groupFact := fact.Clone()
-			engine := NewReteEngine(groupRules)
+			groupFact := fact.share(calls)
+			// 分组继承父引擎配置，组内规则已按优先级排序
+			engine := &ReteEngine{rules: groupRules, options: e.options}
 			groupResults, err := engine.Evaluate(groupFact)
 			mu.Lock()
 			defer mu.Unlock()
@@ -101,6 +169,7 @@ type reteProducer interface {
 // reteAlphaNode 负责单条件过滤与记忆匹配结果
 type reteAlphaNode struct {
 	key       string
+	deps      [][]pathSegment // 条件引用的路径片段，用于判断变更是否影响该节点
 	evaluator func(*Fact) (bool, error)
 	memory    map[int]*reteToken
 	outputs   []reteOutput
@@ -138,6 +207,34 @@ func (n *reteAlphaNode) OnFactRemoved(token *reteToken) {
 	}
 }
 
+func (n *reteAlphaNode) affectedBy(changes [][]pathSegment) bool {
+	for _, change := range changes {
+		for _, dep := range n.deps {
+			if pathsOverlap(dep, change) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func pathsOverlap(a, b []pathSegment) bool {
+	// 逐段比较，较短的一方是另一方的前缀即重叠：修改 user 影响 user.level，修改 user.level 也影响依赖 user 的量词。
+	// 下标与投影在不知道列表长度时无法确定指向的元素（负数下标从末尾计数），彼此之间一律视为匹配
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	for i := range a {
+		x, y := a[i], b[i]
+		if x.kind == segmentKey || y.kind == segmentKey {
+			if x.kind != y.kind || x.key != y.key {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 // reteTrueNode 用于恒真条件的入口节点
 type reteTrueNode struct {
 	outputs []reteOutput
@@ -433,14 +530,17 @@ type reteSession struct {
 }
 
 // newReteSession 构建网络并准备会话状态
-func newReteSession(rules []Rule) (*reteSession, error) {
+func newReteSession(rules []Rule, compiler conditionCompiler) (*reteSession, error) {
 	session := &reteSession{
 		facts:    map[int]*Fact{},
 		agenda:   map[string]map[int]struct{}{},
 		ruleByID: map[string]Rule{},
 	}
+	// Alpha 节点在规则之间共享，无法按规则开启自适应重排，网络中的条件始终按书写顺序执行
+	compiler.adaptive = nil
 	builder := reteBuilder{
 		alphaNodes: map[string]*reteAlphaNode{},
+		compiler:   compiler,
 	}
 	for _, rule := range rules {
 		if rule.Status != "" && strings.ToLower(rule.Status) != RuleStatusActive {
@@ -509,6 +609,34 @@ func (s *reteSession) UpdateFact(id int, fact *Fact) error {
 	return nil
 }
 
+// ApplyChanges 在事实被 SetPath/DeletePath 修改后增量传播：
+// 只重新评估依赖路径与变更路径重叠的 Alpha 节点，其余节点的记忆与激活保持不变
+func (s *reteSession) ApplyChanges(id int, changes []FactChange) error {
+	fact, ok := s.facts[id]
+	if !ok {
+		return errors.New("fact not found")
+	}
+	paths := make([][]pathSegment, 0, len(changes))
+	for _, change := range changes {
+		parsed, err := parseFactPath(change.Path)
+		if err != nil {
+			return fmt.Errorf("apply change %s: %w", change.Path, err)
+		}
+		paths = append(paths, parsed.segments)
+	}
+	token := &reteToken{id: id, fact: fact}
+	for _, alpha := range s.alphaNodes {
+		if !alpha.affectedBy(paths) {
+			continue
+		}
+		alpha.OnFactRemoved(token)
+		if err := alpha.OnFactInserted(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // RemoveFact 撤回事实并清理相关激活
 func (s *reteSession) RemoveFact(id int) {
 	fact, ok := s.facts[id]
@@ -589,6 +717,7 @@ func (s *reteSession) factExists(id int) bool {
 // reteBuilder 将条件树编译为 Rete 网络
 type reteBuilder struct {
 	alphaNodes map[string]*reteAlphaNode
+	compiler   conditionCompiler
 	notNodes   []*reteNotNode
 	trueNode   *reteTrueNode
 }
@@ -655,25 +784,43 @@ func (b *reteBuilder) buildExpr(condition *Condition) (reteProducer, error) {
 		child.AddOutput(node)
 		b.notNodes = append(b.notNodes, node)
 		return node, nil
+	case ConditionAny, ConditionAll, ConditionNone:
+		// 量词的子条件作用于列表元素而非事实本身，整体作为一个 Alpha 条件
+		if err := validateQuantifier(operator, condition); err != nil {
+			return nil, err
+		}
+		return b.buildAlpha(condition)
 	default:
 		if condition.Field == "" {
 			return nil, errors.New("leaf condition requires field")
 		}
-		key, err := alphaKey(condition)
+		return b.buildAlpha(condition)
+	}
+}
+
+// buildAlpha 复用或创建等价条件的 Alpha 节点
+func (b *reteBuilder) buildAlpha(condition *Condition) (reteProducer, error) {
+	key, err := alphaKey(condition)
+	if err != nil {
+		return nil, err
+	}
+	alpha, ok := b.alphaNodes[key]
+	if !ok {
+		evaluator, err := b.compiler.compile(condition)
 		if err != nil {
 			return nil, err
 		}
-		alpha, ok := b.alphaNodes[key]
-		if !ok {
-			evaluator, err := CompileCondition(condition)
-			if err != nil {
-				return nil, err
+		var deps [][]pathSegment
+		for _, dep := range ConditionDependencies(condition) {
+			// 条件已编译成功，引用的路径均可解析
+			if parsed, err := parseFactPath(dep); err == nil {
+				deps = append(deps, parsed.segments)
 			}
-			alpha = &reteAlphaNode{key: key, evaluator: evaluator}
-			b.alphaNodes[key] = alpha
 		}
-		return alpha, nil
+		alpha = &reteAlphaNode{key: key, deps: deps, evaluator: evaluator}
+		b.alphaNodes[key] = alpha
 	}
+	return alpha, nil
 }
 
 // alphaKey 用于对等价叶子条件进行去重
@@ -682,5 +829,13 @@ func alphaKey(condition *Condition) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return condition.Field + "|" + strings.ToLower(condition.Operator) + "|" + string(raw), nil
+	key := condition.Field + "|" + strings.ToLower(condition.Operator) + "|" + string(raw)
+	if len(condition.Children) > 0 {
+		children, err := json.Marshal(condition.Children)
+		if err != nil {
+			return "", err
+		}
+		key += "|" + string(children)
+	}
+	return key, nil
 }
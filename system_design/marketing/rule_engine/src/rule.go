@@ -92,6 +92,30 @@ var DefaultRules = []Rule{
 			{Type: ActionCouponMutex, Params: map[string]interface{}{"reject": CouponTypeFullReduction}},
 		},
 	},
+	{
+		RuleID:      "RULE_PRICE_3",
+		RuleName:    "生鲜大件包邮",
+		Description: "购物车中任一生鲜商品单价不低于50元免运费",
+		Type:        RuleTypePricing,
+		Priority:    64,
+		Status:      RuleStatusActive,
+		Condition: &Condition{
+			Field:    "cart.items",
+			Operator: ConditionAny,
+			Children: []Condition{
+				{
+					Operator: ConditionAnd,
+					Children: []Condition{
+						{Field: "category", Operator: ConditionEq, Value: ItemCategoryFresh},
+						{Field: "price", Operator: ConditionGte, Value: 50},
+					},
+				},
+			},
+		},
+		Actions: []Action{
+			{Type: ActionBenefitSend, Params: map[string]interface{}{"benefit_type": BenefitTypeFreeShipping}},
+		},
+	},
 	{
 		RuleID:      "RULE_RISK_1",
 		RuleName:    "频次控制",
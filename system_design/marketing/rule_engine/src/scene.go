@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrUnknownScene 表示类型、场景或标签没有对应的规则分区
+var ErrUnknownScene = errors.New("unknown scene")
+
+// SceneEngine 在构建时一次性编译全部规则，并按类型、场景与标签预先划分为多个分区引擎。
+// 在线请求只执行所属分区，不需要按类型过滤规则或重新构建引擎
+type SceneEngine struct {
+	// 包含全部规则的引擎
+	all *Engine
+	// 三个维度各自的 路由键 -> 分区引擎，同名的类型、场景与标签互不影响；
+	// 分区与 all 共享已编译的条件执行器
+	types  map[string]*Engine
+	scenes map[string]*Engine
+	tags   map[string]*Engine
+}
+
+// NewSceneEngine 编译规则并按 Type、Scene 与 Tags 划分分区，一条规则可以属于多个分区；
+// opts 对所有分区生效
+func NewSceneEngine(rules []Rule, opts ...EngineOption) *SceneEngine {
+	all := NewEngine(rules, opts...)
+	types := map[string][]compiledRule{}
+	scenes := map[string][]compiledRule{}
+	tags := map[string][]compiledRule{}
+	for _, rule := range all.rules {
+		if rule.meta.Type != "" {
+			types[rule.meta.Type] = append(types[rule.meta.Type], rule)
+		}
+		if rule.meta.Scene != "" {
+			scenes[rule.meta.Scene] = append(scenes[rule.meta.Scene], rule)
+		}
+		for _, tag := range uniqueTags(rule.meta.Tags) {
+			tags[tag] = append(tags[tag], rule)
+		}
+	}
+	return &SceneEngine{
+		all:    all,
+		types:  all.partitions(types),
+		scenes: all.partitions(scenes),
+		tags:   all.partitions(tags),
+	}
+}
+
+func (e *Engine) partitions(rules map[string][]compiledRule) map[string]*Engine {
+	engines := make(map[string]*Engine, len(rules))
+	for key, partition := range rules {
+		engines[key] = newCompiledEngine(partition, e.options, e.compiler)
+	}
+	return engines
+}
+
+func uniqueTags(tags []string) []string {
+	// 去重并忽略空标签，重复的标签不会让规则在分区内执行两次
+	unique := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag == "" {
+			continue
+		}
+		seen := false
+		for _, existing := range unique {
+			if existing == tag {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			unique = append(unique, tag)
+		}
+	}
+	return unique
+}
+
+// All 返回包含全部规则的引擎
+func (s *SceneEngine) All() *Engine {
+	return s.all
+}
+
+// Type 返回规则类型对应的分区引擎，可使用 Engine 的全部评估方式
+func (s *SceneEngine) Type(ruleType string) (*Engine, bool) {
+	engine, ok := s.types[ruleType]
+	return engine, ok
+}
+
+// Scene 返回业务场景对应的分区引擎，可使用 Engine 的全部评估方式
+func (s *SceneEngine) Scene(scene string) (*Engine, bool) {
+	engine, ok := s.scenes[scene]
+	return engine, ok
+}
+
+// Tag 返回标签对应的分区引擎，可使用 Engine 的全部评估方式
+func (s *SceneEngine) Tag(tag string) (*Engine, bool) {
+	engine, ok := s.tags[tag]
+	return engine, ok
+}
+
+// Types 返回全部规则类型，按字典序排列
+func (s *SceneEngine) Types() []string {
+	return partitionKeys(s.types)
+}
+
+// Scenes 返回全部业务场景，按字典序排列
+func (s *SceneEngine) Scenes() []string {
+	return partitionKeys(s.scenes)
+}
+
+// Tags 返回全部标签，按字典序排列
+func (s *SceneEngine) Tags() []string {
+	return partitionKeys(s.tags)
+}
+
+func partitionKeys(engines map[string]*Engine) []string {
+	keys := make([]string, 0, len(engines))
+	for key := range engines {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// EvaluateType 只执行规则类型分区内的规则，未知类型返回 ErrUnknownScene
+func (s *SceneEngine) EvaluateType(ruleType string, fact *Fact) ([]Result, error) {
+	engine, ok := s.types[ruleType]
+	if !ok {
+		return nil, fmt.Errorf("%w: type %s", ErrUnknownScene, ruleType)
+	}
+	return engine.Evaluate(fact.Context(), fact)
+}
+
+// EvaluateScene 只执行业务场景分区内的规则，未知场景返回 ErrUnknownScene
+func (s *SceneEngine) EvaluateScene(scene string, fact *Fact) ([]Result, error) {
+	engine, ok := s.scenes[scene]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownScene, scene)
+	}
+	return engine.Evaluate(fact.Context(), fact)
+}
+
+// EvaluateSceneWith 在 ctx 约束下执行业务场景分区内的规则，选项与 EvaluateWith 一致
+func (s *SceneEngine) EvaluateSceneWith(ctx context.Context, scene string, fact *Fact, opts ...EvaluateOption) (*Evaluation, error) {
+	engine, ok := s.scenes[scene]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownScene, scene)
+	}
+	return engine.EvaluateWith(ctx, fact, opts...)
+}
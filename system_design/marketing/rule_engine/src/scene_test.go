@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// sceneRules 在 homeRules 上分配类型、场景与标签，四条规则在 homeFact 上都会命中
+func sceneRules() []Rule {
+	rules := homeRules(4)
+	for i, route := range []Rule{
+		{RuleID: "TYPE_PROMO", Type: "promo"},
+		{RuleID: "SCENE_PROMO", Type: "pricing", Scene: "promo"},
+		{RuleID: "TAG_PROMO", Type: "pricing", Tags: []string{"promo", "promo", ""}},
+		{RuleID: "CHECKOUT", Type: "pricing", Scene: "checkout", Tags: []string{"new_user"}},
+	} {
+		rules[i].RuleID, rules[i].Type, rules[i].Scene, rules[i].Tags = route.RuleID, route.Type, route.Scene, route.Tags
+	}
+	return rules
+}
+
+func TestSceneEngineKeepsDimensionsApart(t *testing.T) {
+	// 同名的类型、场景与标签各自成为独立分区
+	scenes := NewSceneEngine(sceneRules())
+	cases := []struct {
+		lookup func(string) (*Engine, bool)
+		key    string
+		want   []string
+	}{
+		{scenes.Type, "promo", []string{"TYPE_PROMO"}},
+		{scenes.Scene, "promo", []string{"SCENE_PROMO"}},
+		{scenes.Tag, "promo", []string{"TAG_PROMO"}},
+		{scenes.Type, "pricing", []string{"SCENE_PROMO", "TAG_PROMO", "CHECKOUT"}},
+		{scenes.Scene, "checkout", []string{"CHECKOUT"}},
+		{scenes.Tag, "new_user", []string{"CHECKOUT"}},
+	}
+	for _, c := range cases {
+		engine, ok := c.lookup(c.key)
+		if !ok {
+			t.Fatalf("partition %s missing", c.key)
+		}
+		results, err := engine.Evaluate(context.Background(), homeFact())
+		if err != nil || !reflect.DeepEqual(resultIDs(results), c.want) {
+			t.Fatalf("partition %s = %v, %v", c.key, resultIDs(results), err)
+		}
+	}
+	if got := scenes.Types(); !reflect.DeepEqual(got, []string{"pricing", "promo"}) {
+		t.Fatalf("types = %v", got)
+	}
+	if got := scenes.Scenes(); !reflect.DeepEqual(got, []string{"checkout", "promo"}) {
+		t.Fatalf("scenes = %v", got)
+	}
+	if got := scenes.Tags(); !reflect.DeepEqual(got, []string{"new_user", "promo"}) {
+		t.Fatalf("tags = %v", got)
+	}
+	if _, ok := scenes.Scene("pricing"); ok {
+		t.Fatal("type must not be reachable as a scene")
+	}
+}
+
+func TestSceneEngineEvaluate(t *testing.T) {
+	scenes := NewSceneEngine(sceneRules())
+	results, err := scenes.EvaluateScene("promo", homeFact())
+	if err != nil || !reflect.DeepEqual(resultIDs(results), []string{"SCENE_PROMO"}) {
+		t.Fatalf("EvaluateScene = %v, %v", resultIDs(results), err)
+	}
+	results, err = scenes.EvaluateType("promo", homeFact())
+	if err != nil || !reflect.DeepEqual(resultIDs(results), []string{"TYPE_PROMO"}) {
+		t.Fatalf("EvaluateType = %v, %v", resultIDs(results), err)
+	}
+	evaluation, err := scenes.EvaluateSceneWith(context.Background(), "checkout", homeFact())
+	if err != nil || evaluation.Evaluated != 1 {
+		t.Fatalf("EvaluateSceneWith = %+v, %v", evaluation, err)
+	}
+	if _, err := scenes.EvaluateScene("new_user", homeFact()); !errors.Is(err, ErrUnknownScene) {
+		t.Fatalf("tag as scene = %v", err)
+	}
+	if _, err := scenes.EvaluateType("checkout", homeFact()); !errors.Is(err, ErrUnknownScene) {
+		t.Fatalf("scene as type = %v", err)
+	}
+	if results, err := scenes.All().Evaluate(context.Background(), homeFact()); err != nil || len(results) != 4 {
+		t.Fatalf("All = %v, %v", resultIDs(results), err)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// FactSnapshot 为一次评估的事实快照：调用方提供的数据与各 loader 实际返回的结果，可序列化后用于回放
+type FactSnapshot struct {
+	Data    map[string]interface{}  `json:"data"`
+	Loaders map[string]LoaderRecord `json:"loaders,omitempty"`
+}
+
+// LoaderRecord 为单个 loader 路径的加载结果
+type LoaderRecord struct {
+	Value   interface{} `json:"value,omitempty"`
+	Absent  bool        `json:"absent,omitempty"`  // loader 返回 ErrFactNotFound
+	Error   string      `json:"error,omitempty"`   // 加载失败的原因
+	Subtree bool        `json:"subtree,omitempty"` // 结果按 subtree loader 合并
+	Merge   MergePolicy `json:"merge,omitempty"`
+}
+
+// factRecorder 收集录制期间的加载结果，与克隆共享
+type factRecorder struct {
+	mu      sync.Mutex
+	data    map[string]interface{} // 开始录制时的数据根节点，写入按路径替换，保证其不再被修改
+	source  interface{}
+	loaders map[string]LoaderRecord
+}
+
+var errNotRecording = errors.New("fact is not recording")
+
+// EnableRecording 开始录制：之后每个 loader 返回的值、不存在标记与错误都会被记录，
+// 连同调用方提供的数据一起由 Snapshot 导出。录制从调用时的数据开始，应在评估前调用
+func (f *Fact) EnableRecording() {
+	st := f.state
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.recorder != nil {
+		return
+	}
+	// 写入按路径替换，录制的根节点不会被之后的加载修改
+	st.recorder = &factRecorder{data: st.data, source: f.source, loaders: map[string]LoaderRecord{}}
+}
+
+// Snapshot 导出录制结果；未调用 EnableRecording 时返回错误
+func (f *Fact) Snapshot() (*FactSnapshot, error) {
+	st := f.state
+	st.mu.RLock()
+	rec := st.recorder
+	st.mu.RUnlock()
+	if rec == nil {
+		return nil, errNotRecording
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	snapshot := &FactSnapshot{
+		Data:    deepCopyMap(withSourceFields(rec.source, rec.data)),
+		Loaders: make(map[string]LoaderRecord, len(rec.loaders)),
+	}
+	for path, record := range rec.loaders {
+		record.Value = deepCopyValue(record.Value)
+		snapshot.Loaders[path] = record
+	}
+	return snapshot, nil
+}
+
+func (r *factRecorder) record(path string, loader *factLoader, value batchValue, err error) {
+	record := LoaderRecord{Value: value.val, Absent: value.absent, Subtree: loader.subtree, Merge: loader.merge}
+	if err != nil {
+		// 去掉 "load <path>:" 前缀，回放时由 loader 重新包装
+		if cause := errors.Unwrap(err); cause != nil {
+			err = cause
+		}
+		record = LoaderRecord{Error: err.Error(), Subtree: loader.subtree, Merge: loader.merge}
+	}
+	r.mu.Lock()
+	r.loaders[path] = record
+	r.mu.Unlock()
+}
+
+// ParseFactSnapshot 解析序列化的快照，数值保留为 json.Number
+func ParseFactSnapshot(data []byte) (*FactSnapshot, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var snapshot FactSnapshot
+	if err := decoder.Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("decode snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// NewFactFromSnapshot 以快照重建 Fact：记录过的路径按原结果回放，不调用任何真实 loader。
+// 回放 Fact 忽略之后注册的 loader，未记录的路径按缺失处理，保证与录制时的评估结果一致
+func NewFactFromSnapshot(snapshot *FactSnapshot) *Fact {
+	fact := NewFact(deepCopyMap(snapshot.Data))
+	for path, record := range snapshot.Loaders {
+		l := &factLoader{load: record.replay(), subtree: record.Subtree, merge: record.Merge}
+		fact.setLoader(path, l)
+	}
+	fact.state.replay = true
+	return fact
+}
+
+func (r LoaderRecord) replay() ContextLoader {
+	return func(context.Context) (interface{}, error) {
+		switch {
+		case r.Error != "":
+			return nil, replayError(r.Error)
+		case r.Absent:
+			return nil, ErrFactNotFound
+		default:
+			// 每次返回副本，回放 Fact 的写入不会影响快照
+			return deepCopyValue(r.Value), nil
+		}
+	}
+}
+
+func replayError(msg string) error {
+	// 上下文错误还原为哨兵值，errors.Is 判断与录制时一致
+	for _, sentinel := range []error{context.DeadlineExceeded, context.Canceled} {
+		if msg == sentinel.Error() {
+			return sentinel
+		}
+	}
+	return errors.New(msg)
+}
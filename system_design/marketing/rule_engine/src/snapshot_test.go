@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func recordingRules() []Rule {
+	return []Rule{
+		activeRule("GOLD", 0, leaf("bitmask_all", "user.level_mask", LevelMaskGold)),
+		activeRule("TAGGED", 0, leaf("contains", "user.tags", UserTagHighValue)),
+		activeRule("RISK", 0, leaf("eq", "risk.user_blacklist", false)),
+	}
+}
+
+func TestSnapshotReplaysWithoutLoaders(t *testing.T) {
+	fact := NewFact(map[string]interface{}{"user": map[string]interface{}{"city": UserCityBeijing}})
+	fact.SetLoader("user.level_mask", func() (interface{}, error) { return LevelMaskGold, nil })
+	fact.SetLoader("user.tags", func() (interface{}, error) { return nil, ErrFactNotFound })
+	fact.SetContextLoader("risk.user_blacklist", func(ctx context.Context) (interface{}, error) {
+		return nil, context.DeadlineExceeded
+	}, WithLoaderTimeout(time.Second))
+	fact.EnableRecording()
+	engine := NewEngine(recordingRules(), WithErrorPolicy(ErrorSkipRule))
+	live, liveErr := engine.Evaluate(context.Background(), fact)
+
+	snapshot, err := fact.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 快照的 data 只包含调用方提供的数据
+	if !reflect.DeepEqual(snapshot.Data, map[string]interface{}{"user": map[string]interface{}{"city": UserCityBeijing}}) {
+		t.Fatalf("snapshot data = %v", snapshot.Data)
+	}
+	encoded, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseFactSnapshot(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := NewFactFromSnapshot(parsed)
+	var calls int32
+	replayed.SetLoader("user.level_mask", func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return 0, nil
+	})
+	again, againErr := engine.Evaluate(context.Background(), replayed)
+	if calls != 0 {
+		t.Fatal("replay called a live loader")
+	}
+	if !reflect.DeepEqual(resultIDs(live), resultIDs(again)) || (liveErr == nil) != (againErr == nil) {
+		t.Fatalf("live = %v, %v; replay = %v, %v", resultIDs(live), liveErr, resultIDs(again), againErr)
+	}
+	if _, _, err := replayed.GetPath("risk.user_blacklist"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("replayed error = %v", err)
+	}
+}
+
+func TestSnapshotWithoutRecording(t *testing.T) {
+	if _, err := NewFact(nil).Snapshot(); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestSnapshotRecordsPrefetchAndParallelGroups(t *testing.T) {
+	newFact := func() *Fact {
+		fact := NewFact(nil)
+		fact.SetLoader("user.level_mask", func() (interface{}, error) { return LevelMaskGold, nil })
+		fact.SetLoader("user.tags", func() (interface{}, error) { return []interface{}{UserTagHighValue}, nil })
+		fact.SetLoader("risk.user_blacklist", func() (interface{}, error) { return false, nil })
+		fact.EnableRecording()
+		return fact
+	}
+	groupByID := func(rule Rule) string { return rule.RuleID }
+	for name, evaluate := range map[string]func(*Fact) ([]Result, error){
+		"prefetch": func(f *Fact) ([]Result, error) {
+			return NewEngine(recordingRules(), WithPrefetch(0)).Evaluate(context.Background(), f)
+		},
+		"parallel": func(f *Fact) ([]Result, error) {
+			return NewEngine(recordingRules()).EvaluateParallel(f, groupByID)
+		},
+	} {
+		fact := newFact()
+		live, err := evaluate(fact)
+		if err != nil {
+			t.Fatal(err)
+		}
+		snapshot, _ := fact.Snapshot()
+		if len(snapshot.Loaders) != 3 {
+			t.Fatalf("%s: recorded %v", name, snapshot.Loaders)
+		}
+		replayed, err := evaluate(NewFactFromSnapshot(snapshot))
+		if err != nil || len(replayed) != len(live) {
+			t.Fatalf("%s: replay = %v, %v", name, replayed, err)
+		}
+	}
+}
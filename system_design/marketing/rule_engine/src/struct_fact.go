@@ -0,0 +1,204 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// FieldAccessor 可由生成代码实现，按 json 字段名读取值，绕过反射
+type FieldAccessor interface {
+	GetField(name string) (interface{}, bool)
+}
+
+// NewStructFact 以 Go 结构体（或其指针）作为事实根节点，按 json 标签解析路径，无需先转换为 map
+func NewStructFact(root interface{}) *Fact {
+	fact := NewFact(nil)
+	fact.source = root
+	return fact
+}
+
+// structFieldCache 缓存结构体类型的字段索引：reflect.Type -> map[json 名称][]int
+var structFieldCache sync.Map
+
+func lookupField(current interface{}, key string) (interface{}, bool) {
+	// 按对象类型读取子字段：生成访问器优先，其次是结构体与任意字符串键 map
+	if accessor, ok := current.(FieldAccessor); ok {
+		return accessor.GetField(key)
+	}
+	v := reflect.ValueOf(current)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		index, ok := structFields(v.Type())[key]
+		if !ok {
+			return nil, false
+		}
+		field, err := v.FieldByIndexErr(index)
+		if err != nil {
+			// 嵌入的结构体指针为空
+			return nil, false
+		}
+		return fieldValue(field), true
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		item := v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key()))
+		if !item.IsValid() {
+			return nil, false
+		}
+		return fieldValue(item), true
+	default:
+		return nil, false
+	}
+}
+
+func isStructLike(v interface{}) bool {
+	// 判断元素能否按字段访问：访问器、结构体或结构体指针
+	if _, ok := v.(FieldAccessor); ok {
+		return true
+	}
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t != nil && t.Kind() == reflect.Struct
+}
+
+func fieldValue(v reflect.Value) interface{} {
+	// 标量指针解引用后返回，结构体指针保持原样以免复制
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		if v.Elem().Kind() == reflect.Struct {
+			return v.Interface()
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Interface && v.IsNil() {
+		return nil
+	}
+	// 具名基础类型还原为内置类型，保证与规则常量比较时语义一致
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	return v.Interface()
+}
+
+func structFields(t reflect.Type) map[string][]int {
+	if cached, ok := structFieldCache.Load(t); ok {
+		return cached.(map[string][]int)
+	}
+	actual, _ := structFieldCache.LoadOrStore(t, collectStructFields(t))
+	return actual.(map[string][]int)
+}
+
+// structField 为字段名解析的候选字段
+type structField struct {
+	index  []int
+	tagged bool
+}
+
+func collectStructFields(t reflect.Type) map[string][]int {
+	// 与 encoding/json 一致：导出字段按 json 标签命名，匿名嵌入结构体的字段逐层提升。
+	// 同名字段取最浅一层；同一层有多个时只有唯一带标签的字段胜出，否则该名称整体忽略，更深层的同名字段也不再使用
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+	fields := map[string][]int{}
+	decided := map[string]bool{}
+	visited := map[reflect.Type]bool{}
+	next := []embedded{{typ: t}}
+	for len(next) > 0 {
+		current := next
+		next = nil
+		level := map[string][]structField{}
+		var names []string
+		for _, e := range current {
+			// 更浅层已展开过的类型不再展开，同时避免自引用的嵌入造成死循环
+			if visited[e.typ] {
+				continue
+			}
+			for i := 0; i < e.typ.NumField(); i++ {
+				field := e.typ.Field(i)
+				ft := field.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if field.Anonymous {
+					if !field.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !field.IsExported() {
+					continue
+				}
+				tag := field.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name := strings.Split(tag, ",")[0]
+				index := append(append([]int{}, e.index...), i)
+				if name == "" && field.Anonymous && ft.Kind() == reflect.Struct {
+					next = append(next, embedded{typ: ft, index: index})
+					continue
+				}
+				tagged := name != ""
+				if !tagged {
+					name = field.Name
+				}
+				if decided[name] {
+					continue
+				}
+				if _, ok := level[name]; !ok {
+					names = append(names, name)
+				}
+				level[name] = append(level[name], structField{index: index, tagged: tagged})
+			}
+		}
+		for _, e := range current {
+			visited[e.typ] = true
+		}
+		for _, name := range names {
+			decided[name] = true
+			if index, ok := dominantField(level[name]); ok {
+				fields[name] = index
+			}
+		}
+	}
+	return fields
+}
+
+func dominantField(candidates []structField) ([]int, bool) {
+	// 同层同名字段：唯一一个或唯一带标签的字段胜出
+	if len(candidates) == 1 {
+		return candidates[0].index, true
+	}
+	var winner []int
+	for _, candidate := range candidates {
+		if !candidate.tagged {
+			continue
+		}
+		if winner != nil {
+			return nil, false
+		}
+		winner = candidate.index
+	}
+	return winner, winner != nil
+}
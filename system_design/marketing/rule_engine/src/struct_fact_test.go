@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type testBase struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type testAudit struct {
+	ID      int
+	Created string
+}
+
+type testNamed struct {
+	Name string
+}
+
+type testAlias struct {
+	Name string
+}
+
+type testTagged struct {
+	Name string `json:"Name"`
+}
+
+type testCart struct {
+	Total float64 `json:"total_amount"`
+	Items []struct {
+		SKU   string `json:"sku"`
+		Price int    `json:"price"`
+	} `json:"items"`
+}
+
+type testUser struct {
+	testBase
+	*testAudit
+	City   string    `json:"city"`
+	Level  *string   `json:"level,omitempty"`
+	Cart   *testCart `json:"cart"`
+	Secret string    `json:"-"`
+	hidden string
+}
+
+type testConflict struct {
+	testNamed
+	testAlias
+	testAudit
+}
+
+type testTagWins struct {
+	testNamed
+	testTagged
+}
+
+type testShallowWins struct {
+	testConflict
+	Name string
+}
+
+type testSelf struct {
+	*testSelf
+	Value int `json:"value"`
+}
+
+// jsonKeys 返回 encoding/json 序列化后的顶层字段
+func jsonKeys(t *testing.T, v interface{}) map[string]interface{} {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestStructFieldsMatchEncodingJSON(t *testing.T) {
+	level := "gold"
+	values := []interface{}{
+		testUser{testBase: testBase{ID: 1, Name: "a"}, testAudit: &testAudit{ID: 2, Created: "x"}, City: "北京", Level: &level, Secret: "s", hidden: "h"},
+		testConflict{testNamed: testNamed{Name: "a"}, testAlias: testAlias{Name: "b"}, testAudit: testAudit{ID: 2, Created: "x"}},
+		testTagWins{testNamed: testNamed{Name: "untagged"}, testTagged: testTagged{Name: "tagged"}},
+		testShallowWins{testConflict: testConflict{testNamed: testNamed{Name: "deep"}}, Name: "shallow"},
+		testSelf{Value: 3},
+	}
+	for _, v := range values {
+		want := jsonKeys(t, v)
+		got := structFields(reflect.TypeOf(v))
+		var gotNames, wantNames []string
+		for name := range got {
+			gotNames = append(gotNames, name)
+		}
+		for name := range want {
+			wantNames = append(wantNames, name)
+		}
+		sort.Strings(gotNames)
+		sort.Strings(wantNames)
+		// omitempty 只影响序列化，字段解析仍保留
+		if _, ok := got["level"]; ok && want["level"] == nil {
+			wantNames = append(wantNames, "level")
+			sort.Strings(wantNames)
+		}
+		if !reflect.DeepEqual(gotNames, wantNames) {
+			t.Fatalf("%T: fields %v, encoding/json %v", v, gotNames, wantNames)
+		}
+		fact := NewStructFact(v)
+		for name, value := range want {
+			if value == nil || reflect.TypeOf(value).Kind() == reflect.Map {
+				continue
+			}
+			actual, ok, err := fact.GetPath(name)
+			if err != nil || !ok {
+				t.Fatalf("%T.%s: %v, %v", v, name, ok, err)
+			}
+			if !reflect.DeepEqual(normalizeNumber(actual), normalizeNumber(value)) {
+				t.Fatalf("%T.%s = %v, encoding/json %v", v, name, actual, value)
+			}
+		}
+	}
+}
+
+func TestStructFactEvaluation(t *testing.T) {
+	cart := &testCart{Total: 320}
+	cart.Items = append(cart.Items, struct {
+		SKU   string `json:"sku"`
+		Price int    `json:"price"`
+	}{SKU: "SKU_APPLE", Price: 68})
+	user := &testUser{testBase: testBase{ID: 7}, City: UserCityBeijing, Cart: cart}
+	condition := &Condition{Operator: "AND", Children: []Condition{
+		{Operator: "eq", Field: "city", Value: UserCityBeijing},
+		{Operator: "gte", Field: "cart.total_amount", Value: 300},
+		{Operator: "eq", Field: "cart.items[0].sku", Value: "SKU_APPLE"},
+		{Operator: ConditionAny, Field: "cart.items", Children: []Condition{{Operator: "gt", Field: "price", Value: 50}}},
+	}}
+	for _, fact := range []*Fact{NewStructFact(user), NewStructFact(*user)} {
+		if ok, err := EvaluateCondition(condition, fact); err != nil || !ok {
+			t.Fatalf("interpreted = %v, %v", ok, err)
+		}
+		eval, err := CompileCondition(condition)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := eval(fact); err != nil || !ok {
+			t.Fatalf("compiled = %v, %v", ok, err)
+		}
+	}
+	// 空的嵌入指针按缺失处理，空的字段指针取值为 nil
+	fact := NewStructFact(&testUser{})
+	if v, ok, err := fact.GetPath("level"); err != nil || !ok || v != nil {
+		t.Fatalf("level = %v, %v, %v", v, ok, err)
+	}
+	for _, path := range []string{"Created", "cart.total_amount", "Secret", "hidden"} {
+		if _, ok, err := fact.GetPath(path); ok || err != nil {
+			t.Fatalf("%s should be missing, got %v, %v", path, ok, err)
+		}
+	}
+}
+
+type testAccessor map[string]interface{}
+
+func (a testAccessor) GetField(name string) (interface{}, bool) {
+	v, ok := a[name]
+	return v, ok
+}
+
+func TestStructFactFieldAccessor(t *testing.T) {
+	fact := NewStructFact(testAccessor{"user": testAccessor{"city": UserCityShanghai}})
+	v, ok, err := fact.GetPath("user.city")
+	if err != nil || !ok || v != UserCityShanghai {
+		t.Fatalf("GetPath = %v, %v, %v", v, ok, err)
+	}
+}
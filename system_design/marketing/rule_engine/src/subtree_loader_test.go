@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func profileSubtree(context.Context) (interface{}, error) {
+	return map[string]interface{}{
+		"level_mask": LevelMaskGold,
+		"city":       UserCityShanghai,
+		"profile":    map[string]interface{}{"age": 30, "gender": "f"},
+	}, nil
+}
+
+func TestSubtreeLoaderMergesIntoPartialNode(t *testing.T) {
+	fact := NewFact(map[string]interface{}{"user": map[string]interface{}{
+		"city":    UserCityBeijing,
+		"profile": map[string]interface{}{"age": 18},
+	}})
+	fact.SetSubtreeLoader("user", profileSubtree)
+	if v, ok, err := fact.GetPath("user.level_mask"); !ok || err != nil || v != LevelMaskGold {
+		t.Fatalf("level_mask = %v, %v, %v", v, ok, err)
+	}
+	// 默认调用方数据优先，嵌套 map 逐层合并
+	for path, want := range map[string]interface{}{
+		"user.city":           UserCityBeijing,
+		"user.profile.age":    18,
+		"user.profile.gender": "f",
+	} {
+		if v, _, _ := fact.GetPath(path); v != want {
+			t.Fatalf("%s = %v, want %v", path, v, want)
+		}
+	}
+}
+
+func TestSubtreeLoaderPreferLoaded(t *testing.T) {
+	fact := NewFact(map[string]interface{}{"user": map[string]interface{}{"city": UserCityBeijing}})
+	fact.SetSubtreeLoader("user", profileSubtree, WithMergePolicy(MergePreferLoaded))
+	if v, _, err := fact.GetPath("user.city"); err != nil || v != UserCityBeijing {
+		// 访问已提供的字段不触发加载
+		t.Fatalf("city = %v, %v", v, err)
+	}
+	if _, _, err := fact.GetPath("user.level_mask"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _, _ := fact.GetPath("user.city"); v != UserCityShanghai {
+		t.Fatalf("city = %v, want loaded value", v)
+	}
+}
+
+func TestDeepLoaderCreatesIntermediateNodes(t *testing.T) {
+	fact := NewFact(nil)
+	fact.SetLoader("user.profile.age", func() (interface{}, error) { return 30, nil })
+	if v, ok, err := fact.GetPath("user.profile.age"); !ok || err != nil || v != 30 {
+		t.Fatalf("age = %v, %v, %v", v, ok, err)
+	}
+	if _, ok, _ := fact.GetPath("user.profile"); !ok {
+		t.Fatal("intermediate node not created")
+	}
+
+	// 失败或不存在时不创建中间节点
+	for _, err := range []error{errors.New("backend down"), ErrFactNotFound} {
+		err := err
+		fact := NewFact(nil)
+		fact.SetLoader("user.profile.age", func() (interface{}, error) { return nil, err })
+		fact.GetPath("user.profile.age")
+		if _, ok, _ := fact.GetPath("user"); ok {
+			t.Fatalf("intermediate node created after %v", err)
+		}
+	}
+}
+
+func TestSubtreeLoaderInEvaluation(t *testing.T) {
+	newFact := func() *Fact {
+		fact := NewFact(map[string]interface{}{"user": map[string]interface{}{"city": UserCityBeijing}})
+		fact.SetSubtreeLoader("user", profileSubtree)
+		return fact
+	}
+	rules := []Rule{{RuleID: "R", Status: RuleStatusActive, Condition: &Condition{Operator: "AND", Children: []Condition{
+		{Operator: "eq", Field: "user.city", Value: UserCityBeijing},
+		{Operator: "gte", Field: "user.profile.age", Value: 18},
+	}}}}
+	for name, opts := range map[string][]EngineOption{"lazy": nil, "prefetch": {WithPrefetch(0)}} {
+		results, err := NewEngine(rules, opts...).Evaluate(context.Background(), newFact())
+		if err != nil || len(results) != 1 {
+			t.Fatalf("%s: results = %v, %v", name, results, err)
+		}
+	}
+	if results, err := NewReteEngine(rules).Evaluate(newFact()); err != nil || len(results) != 1 {
+		t.Fatalf("rete: results = %v, %v", results, err)
+	}
+}
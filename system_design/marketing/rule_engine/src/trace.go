@@ -0,0 +1,256 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EvaluationTrace 记录一次评估中需要向调用方解释的细节
+type EvaluationTrace struct {
+	mu sync.Mutex
+	// Coercions 按发生顺序记录的类型转换
+	Coercions []CoercionRecord `json:"coercions,omitempty"`
+	// Rules 按执行顺序记录每条规则的条件树评估过程
+	Rules []*RuleTrace `json:"rules,omitempty"`
+	// Loads 为不属于任何规则的加载，例如评估前的预取
+	Loads []LoadRecord `json:"loads,omitempty"`
+}
+
+// CoercionRecord 描述一次叶子比较中发生的类型转换
+type CoercionRecord struct {
+	RuleID   string      `json:"rule_id"`
+	Field    string      `json:"field"`
+	Operator string      `json:"operator"`
+	Left     interface{} `json:"left"`
+	Right    interface{} `json:"right"`
+	Note     string      `json:"note"`
+}
+
+// 规则级结果
+const (
+	RuleMatched           = "matched"
+	RuleConditionFalse    = "condition_false"
+	RuleError             = "error"
+	RuleInactive          = "inactive"
+	RuleSkippedMutexGroup = "skipped_mutex_group"
+	RuleNotEvaluated      = "not_evaluated"     // 评估上下文结束，规则未执行
+	RuleSkippedHitLimit   = "skipped_hit_limit" // 命中条数已满足执行模式，规则未执行
+	RuleSkippedIndex      = "skipped_index"     // 索引字段的取值不满足规则，条件必然不成立，未执行条件树
+)
+
+// 节点级结果
+const (
+	NodeTrue    = "true"
+	NodeFalse   = "false"
+	NodeError   = "error"
+	NodeSkipped = "skipped" // 被短路，未执行
+)
+
+// RuleTrace 为单条规则的评估记录
+type RuleTrace struct {
+	RuleID   string        `json:"rule_id"`
+	RuleName string        `json:"rule_name,omitempty"`
+	Type     string        `json:"type,omitempty"`
+	Priority int           `json:"priority"`
+	Outcome  string        `json:"outcome"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+	// Condition 与规则条件树一一对应，未执行的分支标记为 skipped
+	Condition *NodeTrace   `json:"condition,omitempty"`
+	Loads     []LoadRecord `json:"loads,omitempty"`
+}
+
+// NodeTrace 为条件树单个节点的评估记录
+type NodeTrace struct {
+	Operator string `json:"operator"`
+	Field    string `json:"field,omitempty"`
+	Outcome  string `json:"outcome"`
+	// Left 为字段取值（已归一化），Right 为参与比较的右值；Missing 表示字段不存在
+	Left     interface{}   `json:"left,omitempty"`
+	Right    interface{}   `json:"right,omitempty"`
+	Missing  bool          `json:"missing,omitempty"`
+	Note     string        `json:"note,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration_ns,omitempty"`
+	// Element 为量词子条件对应的列表下标
+	Element  *int         `json:"element,omitempty"`
+	Children []*NodeTrace `json:"children,omitempty"`
+	// 对应的条件节点，用于按规则定义的顺序排列子节点
+	source *Condition
+}
+
+// LoadRecord 为一次 loader 调用，Paths 为同一次调用加载的全部路径
+type LoadRecord struct {
+	Paths    []string      `json:"paths"`
+	Duration time.Duration `json:"duration_ns"`
+	Error    string        `json:"error,omitempty"`
+}
+
+func (f *Fact) recordCoercion(field, operator string, left, right interface{}, note string) {
+	if f.trace == nil {
+		return
+	}
+	f.trace.mu.Lock()
+	defer f.trace.mu.Unlock()
+	ruleID := ""
+	if f.traceRule != nil {
+		ruleID = f.traceRule.RuleID
+	}
+	f.trace.Coercions = append(f.trace.Coercions, CoercionRecord{
+		RuleID:   ruleID,
+		Field:    field,
+		Operator: operator,
+		Left:     left,
+		Right:    right,
+		Note:     note,
+	})
+}
+
+func (f *Fact) recordLoad(members map[string]*factLoader, duration time.Duration, err error) {
+	if f.trace == nil {
+		return
+	}
+	record := LoadRecord{Paths: make([]string, 0, len(members)), Duration: duration}
+	for path := range members {
+		record.Paths = append(record.Paths, path)
+	}
+	sort.Strings(record.Paths)
+	if err != nil {
+		record.Error = err.Error()
+	}
+	f.trace.mu.Lock()
+	defer f.trace.mu.Unlock()
+	if f.traceRule != nil {
+		f.traceRule.Loads = append(f.traceRule.Loads, record)
+		return
+	}
+	f.trace.Loads = append(f.trace.Loads, record)
+}
+
+func (t *EvaluationTrace) addRule(rule Rule, outcome string) *RuleTrace {
+	ruleTrace := &RuleTrace{RuleID: rule.RuleID, RuleName: rule.RuleName, Type: rule.Type, Priority: rule.Priority, Outcome: outcome}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Rules = append(t.Rules, ruleTrace)
+	return ruleTrace
+}
+
+func traceable(condition *Condition, eval func(*Fact) (bool, error)) func(*Fact) (bool, error) {
+	// 轨迹钩子：包装编译后的节点，评估带有轨迹时记录该节点的结果、耗时与子节点，未开启轨迹时只多一次判断
+	operator := traceOperator(condition)
+	return func(fact *Fact) (bool, error) {
+		if fact.traceRule == nil {
+			return eval(fact)
+		}
+		node := &NodeTrace{Operator: operator, Field: condition.Field, source: condition}
+		parent := fact.traceNode
+		if parent != nil {
+			parent.Children = append(parent.Children, node)
+		} else {
+			fact.traceRule.Condition = node
+		}
+		fact.traceNode = node
+		start := time.Now()
+		ok, err := eval(fact)
+		fact.traceNode = parent
+		node.Duration = time.Since(start)
+		switch {
+		case err != nil:
+			node.Outcome = NodeError
+			node.Error = err.Error()
+		case ok:
+			node.Outcome = NodeTrue
+		default:
+			node.Outcome = NodeFalse
+		}
+		switch operator {
+		case "AND", "OR", "NOT":
+			node.Children = alignChildren(condition, node.Children)
+		}
+		return ok, err
+	}
+}
+
+func traceOperator(condition *Condition) string {
+	// 组合与量词节点记为大写，叶子记为小写，与规则定义的书写无关
+	op := strings.ToUpper(condition.Operator)
+	switch op {
+	case "AND", "OR", "NOT", ConditionAny, ConditionAll, ConditionNone:
+		return op
+	}
+	return strings.ToLower(condition.Operator)
+}
+
+func alignChildren(condition *Condition, executed []*NodeTrace) []*NodeTrace {
+	// 子节点记录按规则定义的顺序排列：自适应重排后的执行顺序还原为原顺序，
+	// 同一子条件执行多次时取最后一次，未执行（短路或编译期折叠）的子条件标记为 skipped
+	children := make([]*NodeTrace, len(condition.Children))
+	for i := range condition.Children {
+		for _, node := range executed {
+			if node.source == &condition.Children[i] {
+				children[i] = node
+			}
+		}
+		if children[i] == nil {
+			children[i] = skippedTrace(&condition.Children[i])
+		}
+	}
+	return children
+}
+
+func tracedElements(parent *Fact, eval func(*Fact) (bool, error)) func(*Fact) (bool, error) {
+	// 量词子条件的记录挂在量词节点下，并标注对应的列表下标
+	node := parent.traceNode
+	index := 0
+	return func(element *Fact) (bool, error) {
+		n := len(node.Children)
+		ok, err := eval(element)
+		if len(node.Children) > n {
+			i := index
+			node.Children[n].Element = &i
+		}
+		index++
+		return ok, err
+	}
+}
+
+func (f *Fact) traceMissing() {
+	// 叶子的字段不存在
+	if f.traceNode != nil {
+		f.traceNode.Missing = true
+	}
+}
+
+func (f *Fact) traceOperands(left, right interface{}, note string) {
+	// 叶子参与比较的左右值（已归一化）与类型转换说明
+	if f.traceNode != nil {
+		f.traceNode.Left = left
+		f.traceNode.Right = right
+		f.traceNode.Note = note
+	}
+}
+
+func skippedTrace(condition *Condition) *NodeTrace {
+	// 被短路的子树整体标记为 skipped，保留结构便于对照规则定义
+	if condition == nil {
+		return &NodeTrace{Outcome: NodeSkipped}
+	}
+	op := strings.ToUpper(condition.Operator)
+	node := &NodeTrace{Operator: op, Field: condition.Field, Outcome: NodeSkipped}
+	switch op {
+	case "AND", "OR", "NOT", ConditionAny, ConditionAll, ConditionNone:
+	default:
+		node.Operator = strings.ToLower(condition.Operator)
+		node.Right = condition.Value
+	}
+	if op == ConditionAny || op == ConditionAll || op == ConditionNone {
+		// 量词子条件针对元素执行，未执行时不展开
+		return node
+	}
+	for i := range condition.Children {
+		node.Children = append(node.Children, skippedTrace(&condition.Children[i]))
+	}
+	return node
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func traceRules() []Rule {
+	return []Rule{
+		activeRule("SCENE", 3, and(leaf("eq", "scene", "home"), leaf("gt", "amount", 100))),
+		activeRule("OTHER_SCENE", 2, leaf("eq", "scene", "cart")),
+		activeRule("ITEMS", 1, or(
+			&Condition{Operator: "ANY", Field: "items", Children: []Condition{*leaf("eq", "sku", "B")}},
+			leaf("eq", "coupon", "VIP"),
+		)),
+	}
+}
+
+func traceData() map[string]interface{} {
+	data := homeData()
+	data["amount"] = 50
+	data["items"] = []interface{}{map[string]interface{}{"sku": "A"}, map[string]interface{}{"sku": "B"}}
+	return data
+}
+
+func TestTraceMatchesUntracedEvaluation(t *testing.T) {
+	for _, name := range []string{"default", "adaptive", "unindexed"} {
+		var options []EngineOption
+		switch name {
+		case "adaptive":
+			options = append(options, WithAdaptiveOrdering(AdaptiveConfig{SampleEvery: 1, ReorderEvery: 1}))
+		case "unindexed":
+			options = append(options, WithoutRuleIndex())
+		}
+		engine := NewEngine(traceRules(), options...)
+		for i := 0; i < 8; i++ {
+			want, err := engine.Evaluate(context.Background(), NewFact(traceData()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, trace, err := engine.EvaluateWithTrace(NewFact(traceData()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(resultIDs(got), resultIDs(want)) {
+				t.Fatalf("%s: traced = %v, untraced = %v", name, resultIDs(got), resultIDs(want))
+			}
+			if len(trace.Rules) != len(traceRules()) {
+				t.Fatalf("%s: traced rules = %d", name, len(trace.Rules))
+			}
+		}
+	}
+}
+
+func TestTraceUsesRuleIndex(t *testing.T) {
+	_, trace, err := NewEngine(traceRules()).EvaluateWithTrace(NewFact(traceData()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	skipped := findRuleTrace(trace, "OTHER_SCENE")
+	if skipped == nil || skipped.Outcome != RuleSkippedIndex || skipped.Condition != nil {
+		t.Fatalf("index skipped rule = %+v", skipped)
+	}
+	_, trace, err = NewEngine(traceRules(), WithoutRuleIndex()).EvaluateWithTrace(NewFact(traceData()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule := findRuleTrace(trace, "OTHER_SCENE"); rule.Outcome != RuleConditionFalse {
+		t.Fatalf("unindexed rule = %+v", rule)
+	}
+}
+
+func TestTraceNodeStructure(t *testing.T) {
+	_, trace, err := NewEngine(traceRules()).EvaluateWithTrace(NewFact(traceData()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scene := findRuleTrace(trace, "SCENE").Condition
+	if scene.Operator != "AND" || scene.Outcome != NodeFalse || len(scene.Children) != 2 {
+		t.Fatalf("AND node = %+v", scene)
+	}
+	leaf := scene.Children[1]
+	if leaf.Field != "amount" || leaf.Outcome != NodeFalse || leaf.Left != 50 || leaf.Right != 100 {
+		t.Fatalf("leaf = %+v", leaf)
+	}
+
+	items := findRuleTrace(trace, "ITEMS").Condition
+	if items.Operator != "OR" || items.Outcome != NodeTrue || len(items.Children) != 2 {
+		t.Fatalf("OR node = %+v", items)
+	}
+	if items.Children[1].Outcome != NodeSkipped {
+		t.Fatalf("short-circuited child = %+v", items.Children[1])
+	}
+	quantifier := items.Children[0]
+	if quantifier.Operator != ConditionAny || len(quantifier.Children) != 2 {
+		t.Fatalf("quantifier = %+v", quantifier)
+	}
+	for i, element := range quantifier.Children {
+		if element.Element == nil || *element.Element != i {
+			t.Fatalf("element %d = %+v", i, element)
+		}
+	}
+	if quantifier.Children[1].Outcome != NodeTrue || quantifier.Children[1].Left != "B" {
+		t.Fatalf("matching element = %+v", quantifier.Children[1])
+	}
+}
+
+func TestTraceMissingField(t *testing.T) {
+	rules := []Rule{{RuleID: "R", Status: RuleStatusActive, Condition: &Condition{Operator: "gt", Field: "amount", Value: 1}}}
+	_, trace, err := NewEngine(rules).EvaluateWithTrace(NewFact(map[string]interface{}{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := trace.Rules[0].Condition
+	if node == nil || !node.Missing || node.Outcome != NodeFalse {
+		t.Fatalf("missing leaf = %+v", node)
+	}
+}
+
+func TestTraceKeepsDefinitionOrderUnderAdaptiveOrdering(t *testing.T) {
+	// 两个子条件都总是短路，重排取决于采样耗时；无论执行顺序如何，轨迹都按书写顺序排列子节点
+	rules := []Rule{activeRule("R", 0, and(leaf("eq", "scene", "cart"), leaf("eq", "vip", false)))}
+	data := homeData
+	engine := NewEngine(rules, WithAdaptiveOrdering(AdaptiveConfig{SampleEvery: 1, ReorderEvery: 1}), WithoutRuleIndex())
+	for i := 0; i < 64; i++ {
+		if _, err := engine.Evaluate(context.Background(), NewFact(data())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, trace, err := engine.EvaluateWithTrace(NewFact(data()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := trace.Rules[0].Condition
+	if len(node.Children) != 2 || node.Children[0].Field != "scene" || node.Children[1].Field != "vip" {
+		t.Fatalf("children = %+v", node.Children)
+	}
+	// 是否已重排取决于采样耗时：先执行的子条件为 false，另一个被短路
+	outcomes := []string{node.Children[0].Outcome, node.Children[1].Outcome}
+	sort.Strings(outcomes)
+	if !reflect.DeepEqual(outcomes, []string{NodeFalse, NodeSkipped}) {
+		t.Fatalf("outcomes = %v", outcomes)
+	}
+}
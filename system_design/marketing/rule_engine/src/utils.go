@@ -1,23 +1,104 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"math"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
-func compareNumber(left, right interface{}, cmp func(a, b float64) bool) (bool, error) {
-	// 数值比较的统一入口
+var (
+	errLeftNotNumber  = errors.New("left is not number")
+	errRightNotNumber = errors.New("right is not number")
+	errBitmaskLeft    = errors.New("left is not integer for bitmask_all")
+	errBitmaskRight   = errors.New("right is not integer for bitmask_all")
+)
+
+// comparator 封装叶子比较的数值与类型转换语义，由引擎按规则集配置
+type comparator struct {
+	numeric  NumericMode
+	decimal  DecimalConfig
+	coercion CoercionPolicy
+}
+
+// defaultComparator 沿用 float64 比较，供 CompileCondition 与 EvaluateCondition 使用
+var defaultComparator = comparator{numeric: NumericFloat, decimal: DefaultDecimalConfig}
+
+func (c comparator) compareNumber(left, right interface{}) (int, string, error) {
+	// 数值比较的统一入口，返回 -1/0/1 以及类型转换说明
+	left, leftNote := c.coerceNumber("left", left)
+	right, rightNote := c.coerceNumber("right", right)
+	note := joinNotes(leftNote, rightNote)
+	if c.numeric == NumericDecimal {
+		ld, ok := toDecimal(left)
+		if !ok {
+			return 0, note, errLeftNotNumber
+		}
+		rd, ok := toDecimal(right)
+		if !ok {
+			return 0, note, errRightNotNumber
+		}
+		return ld.Cmp(rd), note, nil
+	}
+	if li, ok := toInt64(left); ok {
+		if ri, ok := toInt64(right); ok {
+			// 双方都是整数时精确比较，超过 2^53 的 ID、掩码不受 float64 精度影响
+			return compareInt64(li, ri), note, nil
+		}
+	}
 	lf, ok := toFloat(left)
 	if !ok {
-		return false, errors.New("left is not number")
+		return 0, note, errLeftNotNumber
 	}
 	rf, ok := toFloat(right)
 	if !ok {
-		return false, errors.New("right is not number")
+		return 0, note, errRightNotNumber
+	}
+	if math.IsNaN(lf) || math.IsNaN(rf) {
+		return 0, note, errors.New("number is NaN")
+	}
+	switch {
+	case lf < rf:
+		return -1, note, nil
+	case lf > rf:
+		return 1, note, nil
+	default:
+		return 0, note, nil
 	}
-	return cmp(lf, rf), nil
+}
+
+func (c comparator) round(d Decimal) Decimal {
+	return d.Round(c.decimal.Scale, c.decimal.Rounding)
+}
+
+func (c comparator) multiply(left, right interface{}) (Decimal, error) {
+	// 折扣等乘法计算：十进制模式精确相乘后舍入，浮点模式保留 float64 结果
+	if c.numeric == NumericDecimal {
+		ld, ok := toDecimal(left)
+		if !ok {
+			return Decimal{}, errLeftNotNumber
+		}
+		rd, ok := toDecimal(right)
+		if !ok {
+			return Decimal{}, errRightNotNumber
+		}
+		return c.round(ld.Mul(rd)), nil
+	}
+	lf, ok := toFloat(left)
+	if !ok {
+		return Decimal{}, errLeftNotNumber
+	}
+	rf, ok := toFloat(right)
+	if !ok {
+		return Decimal{}, errRightNotNumber
+	}
+	d, ok := toDecimal(lf * rf)
+	if !ok {
+		return Decimal{}, errors.New("product is not finite")
+	}
+	return d, nil
 }
 
 func toFloat(v interface{}) (float64, bool) {
@@ -27,6 +108,8 @@ func toFloat(v interface{}) (float64, bool) {
 		return float64(t), true
 	case int64:
 		return float64(t), true
+	case uint64:
+		return float64(t), true
 	case float64:
 		return t, true
 	case float32:
@@ -47,6 +130,48 @@ type jsonNumber interface {
 	Float64() (float64, error)
 }
 
+func toInt64(v interface{}) (int64, bool) {
+	// 只识别可精确表示为 int64 的整数，浮点数与越界的 uint64 交由 toFloat 处理
+	switch t := v.(type) {
+	case int:
+		return int64(t), true
+	case int8:
+		return int64(t), true
+	case int16:
+		return int64(t), true
+	case int32:
+		return int64(t), true
+	case int64:
+		return t, true
+	case uint:
+		return int64(t), uint64(t) <= math.MaxInt64
+	case uint8:
+		return int64(t), true
+	case uint16:
+		return int64(t), true
+	case uint32:
+		return int64(t), true
+	case uint64:
+		return int64(t), t <= math.MaxInt64
+	case json.Number:
+		i, err := t.Int64()
+		return i, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func compareInt64(l, r int64) int {
+	switch {
+	case l < r:
+		return -1
+	case l > r:
+		return 1
+	default:
+		return 0
+	}
+}
+
 func toUint64(v interface{}) (uint64, bool) {
 	switch t := v.(type) {
 	case int:
@@ -85,32 +210,56 @@ func toUint64(v interface{}) (uint64, bool) {
 	case uint64:
 		return t, true
 	case float64:
-		if t < 0 || math.Trunc(t) != t {
-			return 0, false
-		}
-		return uint64(t), true
+		return floatToUint64(t)
 	case float32:
-		if t < 0 || math.Trunc(float64(t)) != float64(t) {
-			return 0, false
+		return floatToUint64(float64(t))
+	case json.Number:
+		// 先按整数解析保留全部 64 位，科学计数法等写法再按浮点处理
+		if u, err := strconv.ParseUint(t.String(), 10, 64); err == nil {
+			return u, true
 		}
-		return uint64(t), true
-	case jsonNumber:
 		f, err := t.Float64()
 		if err != nil {
 			return 0, false
 		}
-		if f < 0 || math.Trunc(f) != f {
+		return floatToUint64(f)
+	case jsonNumber:
+		f, err := t.Float64()
+		if err != nil {
 			return 0, false
 		}
-		return uint64(f), true
+		return floatToUint64(f)
 	default:
 		return 0, false
 	}
 }
 
-func isEqual(left, right interface{}) bool {
-	// 先归一化数值，再进行深度比较
-	return reflect.DeepEqual(normalizeNumber(left), normalizeNumber(right))
+func floatToUint64(f float64) (uint64, bool) {
+	if f < 0 || math.Trunc(f) != f || f >= math.MaxUint64 {
+		return 0, false
+	}
+	return uint64(f), true
+}
+
+func (c comparator) isEqual(left, right interface{}) (bool, string, error) {
+	// 先按策略对齐类型并归一化数值，再进行深度比较
+	left, right, note, err := c.coercePair(left, right)
+	if err != nil {
+		return false, note, err
+	}
+	if c.numeric == NumericDecimal {
+		ld, lok := toDecimal(left)
+		rd, rok := toDecimal(right)
+		if lok && rok {
+			return ld.Cmp(rd) == 0, note, nil
+		}
+	}
+	if li, ok := toInt64(left); ok {
+		if ri, ok := toInt64(right); ok {
+			return li == ri, note, nil
+		}
+	}
+	return reflect.DeepEqual(normalizeNumber(left), normalizeNumber(right)), note, nil
 }
 
 func normalizeNumber(v interface{}) interface{} {
@@ -121,51 +270,67 @@ func normalizeNumber(v interface{}) interface{} {
 	return v
 }
 
-func bitmaskAll(left, right interface{}) (bool, error) {
+func (c comparator) bitmaskAll(left, right interface{}) (bool, string, error) {
+	left, leftNote := c.coerceNumber("left", left)
+	right, rightNote := c.coerceNumber("right", right)
+	note := joinNotes(leftNote, rightNote)
 	lv, ok := toUint64(left)
 	if !ok {
-		return false, errors.New("left is not integer for bitmask_all")
+		return false, note, errBitmaskLeft
 	}
 	rv, ok := toUint64(right)
 	if !ok {
-		return false, errors.New("right is not integer for bitmask_all")
+		return false, note, errBitmaskRight
 	}
-	return (lv & rv) == rv, nil
+	return (lv & rv) == rv, note, nil
 }
 
-func isIn(left, right interface{}) (bool, error) {
+func (c comparator) isIn(left, right interface{}) (bool, string, error) {
 	// 判断 left 是否存在于列表 right 中
 	rv := reflect.ValueOf(right)
 	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
-		return false, errors.New("right is not list for in")
+		return false, "", errors.New("right is not list for in")
 	}
+	notes := ""
 	for i := 0; i < rv.Len(); i++ {
-		if isEqual(left, rv.Index(i).Interface()) {
-			return true, nil
+		matched, note, err := c.isEqual(left, rv.Index(i).Interface())
+		notes = joinNotes(notes, note)
+		if err != nil {
+			return false, notes, err
+		}
+		if matched {
+			return true, notes, nil
 		}
 	}
-	return false, nil
+	return false, notes, nil
 }
 
-func contains(left, right interface{}) (bool, error) {
+func (c comparator) contains(left, right interface{}) (bool, string, error) {
 	// 支持字符串包含与数组包含两种语义
 	switch l := left.(type) {
 	case string:
-		r, ok := right.(string)
-		if !ok {
-			return false, errors.New("right is not string for contains")
+		// 右值按类型转换策略转为文本：宽松策略下 "SKU300" contains 300 成立
+		r, note, err := c.coerceText("right", right)
+		if err != nil {
+			return false, note, err
 		}
-		return strings.Contains(l, r), nil
+		return strings.Contains(l, r), note, nil
 	default:
 		rv := reflect.ValueOf(left)
 		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
-			return false, errors.New("left is not list for contains")
+			return false, "", errors.New("left is not list for contains")
 		}
+		notes := ""
 		for i := 0; i < rv.Len(); i++ {
-			if isEqual(rv.Index(i).Interface(), right) {
-				return true, nil
+			matched, note, err := c.isEqual(rv.Index(i).Interface(), right)
+			notes = joinNotes(notes, note)
+			if err != nil {
+				return false, notes, err
+			}
+			if matched {
+				return true, notes, nil
 			}
 		}
-		return false, nil
+		return false, notes, nil
 	}
 }